@@ -22,11 +22,21 @@ compile() {
   errcheck $?
   go build ./...
   errcheck $?
+  pushd sabivet
+  go vet ./...
+  errcheck $?
+  go build ./...
+  errcheck $?
+  popd
 }
 
 test() {
   go test -v $(go list ./... | grep -v /benchmark)
   errcheck $?
+  pushd sabivet
+  go test -v ./...
+  errcheck $?
+  popd
 }
 
 unit() {
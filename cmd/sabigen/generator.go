@@ -0,0 +1,338 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// FailToParsePackage represents an error reason indicating that the Go files in the directory
+	// could not be parsed.
+	FailToParsePackage struct {
+		Dir string
+	}
+
+	// NoPackageFound represents an error reason indicating that the directory has no Go files
+	// other than tests and the output file.
+	NoPackageFound struct {
+		Dir string
+	}
+
+	// DataInterfaceNotFound represents an error reason indicating that a logic data interface
+	// specified to the generator is not declared in the package.
+	DataInterfaceNotFound struct {
+		Name string
+	}
+
+	// DataAccNotFound represents an error reason indicating that a DataAcc type specified to the
+	// generator is not declared as a struct type in the package, or that no DataAcc type is
+	// found when none is specified.
+	DataAccNotFound struct {
+		Name string
+	}
+
+	// UnresolvableEmbeddedInterface represents an error reason indicating that a logic data
+	// interface embeds an interface which is not declared in the package, so its methods cannot
+	// be determined.
+	UnresolvableEmbeddedInterface struct {
+		Interface string
+		Embedded  string
+	}
+
+	// UnprovidedMethods represents an error reason indicating that methods of the logic data
+	// interfaces are provided by no DataAcc type. Each method is in the form of
+	// "Interface.Method".
+	UnprovidedMethods struct {
+		Methods []string
+	}
+
+	// AmbiguousMethods represents an error reason indicating that methods of the logic data
+	// interfaces are provided by more than one DataAcc type, which makes the selectors of the
+	// generated hub ambiguous. Each method is in the form of "Interface.Method".
+	AmbiguousMethods struct {
+		Methods []string
+	}
+
+	// FailToFormatSource represents an error reason indicating that the generated source could
+	// not be formatted.
+	FailToFormatSource struct {
+		Hub string
+	}
+)
+
+// Config is the configuration of Generate.
+type Config struct {
+	// Dir is the directory of the package.
+	Dir string
+	// Hub is the name of the DataHub struct to generate.
+	Hub string
+	// Data are the names of the logic data interfaces.
+	Data []string
+	// Accs are the names of the DataAcc types. If empty, all struct types in the package
+	// embedding sabi.DataAcc are used.
+	Accs []string
+	// Excludes are the names of the files in Dir which are not parsed, such as the output file.
+	Excludes []string
+}
+
+type pkgInfo struct {
+	name     string
+	ifaces   map[string]*ast.InterfaceType
+	accs     []string
+	isStruct map[string]bool
+	methods  map[string]map[string]bool
+}
+
+// Generate parses the package and returns the source of the DataHub composition struct.
+func Generate(cfg Config) ([]byte, errs.Err) {
+	pkg, err := parsePackage(cfg.Dir, cfg.Excludes)
+	if err.IsNotOk() {
+		return nil, err
+	}
+
+	accs := cfg.Accs
+	if len(accs) == 0 {
+		accs = pkg.accs
+		if len(accs) == 0 {
+			return nil, errs.New(DataAccNotFound{})
+		}
+	}
+	for _, acc := range accs {
+		if !pkg.isStruct[acc] {
+			return nil, errs.New(DataAccNotFound{Name: acc})
+		}
+	}
+
+	var unprovided, ambiguous []string
+	for _, data := range cfg.Data {
+		if _, ok := pkg.ifaces[data]; !ok {
+			return nil, errs.New(DataInterfaceNotFound{Name: data})
+		}
+		methods, err := pkg.interfaceMethods(data, map[string]bool{})
+		if err.IsNotOk() {
+			return nil, err
+		}
+		for _, m := range methods {
+			n := 0
+			for _, acc := range accs {
+				if pkg.methods[acc][m] {
+					n++
+				}
+			}
+			switch {
+			case n == 0:
+				unprovided = append(unprovided, data+"."+m)
+			case n > 1:
+				ambiguous = append(ambiguous, data+"."+m)
+			}
+		}
+	}
+	if len(unprovided) > 0 {
+		return nil, errs.New(UnprovidedMethods{Methods: unprovided})
+	}
+	if len(ambiguous) > 0 {
+		return nil, errs.New(AmbiguousMethods{Methods: ambiguous})
+	}
+
+	return generateSource(pkg.name, cfg.Hub, cfg.Data, accs)
+}
+
+func parsePackage(dir string, excludes []string) (*pkgInfo, errs.Err) {
+	excluded := make(map[string]bool, len(excludes))
+	for _, name := range excludes {
+		excluded[filepath.Base(name)] = true
+	}
+
+	entries, e := os.ReadDir(dir)
+	if e != nil {
+		return nil, errs.New(FailToParsePackage{Dir: dir}, e)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") ||
+			strings.HasSuffix(name, "_test.go") || excluded[name] {
+			continue
+		}
+		f, e := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if e != nil {
+			return nil, errs.New(FailToParsePackage{Dir: dir}, e)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, errs.New(NoPackageFound{Dir: dir})
+	}
+
+	pkg := &pkgInfo{
+		name:     files[0].Name.Name,
+		ifaces:   make(map[string]*ast.InterfaceType),
+		isStruct: make(map[string]bool),
+		methods:  make(map[string]map[string]bool),
+	}
+	for _, f := range files {
+		sabiName := sabiImportName(f)
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					switch t := ts.Type.(type) {
+					case *ast.InterfaceType:
+						pkg.ifaces[ts.Name.Name] = t
+					case *ast.StructType:
+						pkg.isStruct[ts.Name.Name] = true
+						if len(sabiName) > 0 && embedsDataAcc(t, sabiName) {
+							pkg.accs = append(pkg.accs, ts.Name.Name)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil || len(d.Recv.List) == 0 {
+					continue
+				}
+				recv := receiverTypeName(d.Recv.List[0].Type)
+				if len(recv) == 0 {
+					continue
+				}
+				if pkg.methods[recv] == nil {
+					pkg.methods[recv] = make(map[string]bool)
+				}
+				pkg.methods[recv][d.Name.Name] = true
+			}
+		}
+	}
+	sort.Strings(pkg.accs)
+	return pkg, errs.Ok()
+}
+
+func sabiImportName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if imp.Path.Value != `"github.com/sttk/sabi"` {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "sabi"
+	}
+	return ""
+}
+
+func embedsDataAcc(t *ast.StructType, sabiName string) bool {
+	for _, field := range t.Fields.List {
+		if len(field.Names) > 0 {
+			continue
+		}
+		if sel, ok := field.Type.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == sabiName && sel.Sel.Name == "DataAcc" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func (pkg *pkgInfo) interfaceMethods(name string, visited map[string]bool) ([]string, errs.Err) {
+	if visited[name] {
+		return nil, errs.Ok()
+	}
+	visited[name] = true
+
+	var methods []string
+	for _, field := range pkg.ifaces[name].Methods.List {
+		if len(field.Names) > 0 {
+			for _, n := range field.Names {
+				methods = append(methods, n.Name)
+			}
+			continue
+		}
+		ident, ok := field.Type.(*ast.Ident)
+		if !ok {
+			return nil, errs.New(UnresolvableEmbeddedInterface{
+				Interface: name, Embedded: exprString(field.Type)})
+		}
+		if _, ok := pkg.ifaces[ident.Name]; !ok {
+			return nil, errs.New(UnresolvableEmbeddedInterface{
+				Interface: name, Embedded: ident.Name})
+		}
+		embedded, err := pkg.interfaceMethods(ident.Name, visited)
+		if err.IsNotOk() {
+			return nil, err
+		}
+		methods = append(methods, embedded...)
+	}
+	return methods, errs.Ok()
+}
+
+func exprString(expr ast.Expr) string {
+	var b bytes.Buffer
+	_ = format.Node(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+func generateSource(pkgName, hub string, data, accs []string) ([]byte, errs.Err) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by sabigen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "import \"github.com/sttk/sabi\"\n\n")
+
+	fmt.Fprintf(&b, "// %s is a DataHub which implements %s.\n", hub, strings.Join(data, ", "))
+	fmt.Fprintf(&b, "type %s struct {\n\tsabi.DataHub\n", hub)
+	for _, acc := range accs {
+		fmt.Fprintf(&b, "\t*%s\n", acc)
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// New%s creates a new %s whose DataAccs access the DataHub.\n", hub, hub)
+	fmt.Fprintf(&b, "func New%s() %s {\n\thub := sabi.NewDataHub()\n", hub, hub)
+	fmt.Fprintf(&b, "\treturn %s{\n\t\tDataHub: hub,\n", hub)
+	for _, acc := range accs {
+		fmt.Fprintf(&b, "\t\t%s: &%s{DataAcc: hub},\n", acc, acc)
+	}
+	fmt.Fprintf(&b, "\t}\n}\n\n")
+
+	for _, d := range data {
+		fmt.Fprintf(&b, "var _ %s = (*%s)(nil)\n", d, hub)
+	}
+
+	src, e := format.Source(b.Bytes())
+	if e != nil {
+		return nil, errs.New(FailToFormatSource{Hub: hub}, e)
+	}
+	return src, errs.Ok()
+}
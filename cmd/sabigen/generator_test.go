@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("with DataAccs", func(t *testing.T) {
+		src, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"MyData", "OtherData"},
+			Accs: []string{"GettingDataAcc", "SettingDataAcc"},
+		})
+		assert.True(t, err.IsOk())
+
+		expected, e := os.ReadFile("testdata/my-data-hub_gen.go.golden")
+		assert.Nil(t, e)
+		assert.Equal(t, string(src), string(expected))
+	})
+
+	t.Run("all DataAccs in package", func(t *testing.T) {
+		src, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"MyData"},
+		})
+		assert.True(t, err.IsOk())
+		assert.Contains(t, string(src), "\t*AnotherGettingDataAcc\n\t*GettingDataAcc\n\t*SettingDataAcc\n")
+		assert.Contains(t, string(src),
+			"AnotherGettingDataAcc: &AnotherGettingDataAcc{DataAcc: hub},")
+		assert.NotContains(t, string(src), "NotDataAcc")
+	})
+
+	t.Run("unprovided methods", func(t *testing.T) {
+		_, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"MyData", "BrokenData"},
+			Accs: []string{"GettingDataAcc", "SettingDataAcc"},
+		})
+		assert.Equal(t, err.Reason(), UnprovidedMethods{
+			Methods: []string{"BrokenData.Delete", "BrokenData.Purge"},
+		})
+	})
+
+	t.Run("ambiguous methods", func(t *testing.T) {
+		_, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"OtherData"},
+			Accs: []string{"GettingDataAcc", "GettingDataAcc"},
+		})
+		assert.Equal(t, err.Reason(), AmbiguousMethods{Methods: []string{"OtherData.Count"}})
+	})
+
+	t.Run("data interface not found", func(t *testing.T) {
+		_, err := Generate(Config{Dir: "testdata/app", Hub: "MyDataHub", Data: []string{"NoData"}})
+		assert.Equal(t, err.Reason(), DataInterfaceNotFound{Name: "NoData"})
+	})
+
+	t.Run("DataAcc not found", func(t *testing.T) {
+		_, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"MyData"},
+			Accs: []string{"NoDataAcc"},
+		})
+		assert.Equal(t, err.Reason(), DataAccNotFound{Name: "NoDataAcc"})
+	})
+
+	t.Run("unresolvable embedded interface", func(t *testing.T) {
+		_, err := Generate(Config{
+			Dir:  "testdata/app",
+			Hub:  "MyDataHub",
+			Data: []string{"ForeignData"},
+		})
+		assert.Equal(t, err.Reason(),
+			UnresolvableEmbeddedInterface{Interface: "ForeignData", Embedded: "error"})
+	})
+
+	t.Run("no package", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := Generate(Config{Dir: dir, Hub: "MyDataHub", Data: []string{"MyData"}})
+		assert.Equal(t, err.Reason(), NoPackageFound{Dir: dir})
+
+		_, err = Generate(Config{
+			Dir: filepath.Join(dir, "none"), Hub: "MyDataHub", Data: []string{"MyData"}})
+		assert.Equal(t, err.Reason(), FailToParsePackage{Dir: filepath.Join(dir, "none")})
+	})
+
+	t.Run("exclude output file", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"logic.go", "data-acc.go"} {
+			b, e := os.ReadFile(filepath.Join("testdata/app", name))
+			assert.Nil(t, e)
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, name), b, 0o644))
+		}
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "my-data-hub_gen.go"), []byte("broken"), 0o644))
+
+		_, err := Generate(Config{
+			Dir:      dir,
+			Hub:      "MyDataHub",
+			Data:     []string{"MyData"},
+			Excludes: []string{"my-data-hub_gen.go"},
+		})
+		assert.True(t, err.IsOk())
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// Sabigen generates a DataHub composition struct for logic data interfaces and DataAcc types.
+//
+// For the logic data interfaces and the DataAcc types declared in a package, it generates a
+// struct which embeds sabi.DataHub and a pointer to each DataAcc type, a constructor which
+// initializes every DataAcc with the DataHub, and compile-time assertions that the struct
+// implements every data interface. The constructor returns the struct type instead of
+// sabi.DataHub, so that the hub can be passed to sabi.RunOf and sabi.TxnOf. If some methods of
+// the data interfaces are provided by no DataAcc, or by more than one, sabigen reports them and
+// generates nothing.
+//
+// Usage:
+//
+//	sabigen -hub MyDataHub -data MyData[,OtherData] [-acc GettingDataAcc,SettingDataAcc]
+//	        [-dir .] [-o my-data-hub_gen.go]
+//
+// If -acc is omitted, all struct types in the package embedding sabi.DataAcc are used.
+// If -o is omitted, the output file is the hub name in kebab case followed by "_gen.go".
+// Sabigen is intended to be run by go generate:
+//
+//	//go:generate go run github.com/sttk/sabi/cmd/sabigen -hub MyDataHub -data MyData
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("sabigen", flag.ContinueOnError)
+	hub := fs.String("hub", "", "name of the DataHub struct to generate (required)")
+	data := fs.String("data", "", "comma-separated names of logic data interfaces (required)")
+	acc := fs.String("acc", "", "comma-separated names of DataAcc types (default: all in package)")
+	dir := fs.String("dir", ".", "directory of the package")
+	out := fs.String("o", "", "output file name (default: <hub-in-kebab-case>_gen.go)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(*hub) == 0 || len(*data) == 0 {
+		fmt.Fprintln(os.Stderr, "sabigen: -hub and -data are required")
+		fs.Usage()
+		return 2
+	}
+	if len(*out) == 0 {
+		*out = kebabCase(*hub) + "_gen.go"
+	}
+
+	cfg := Config{
+		Dir:      *dir,
+		Hub:      *hub,
+		Data:     splitNames(*data),
+		Accs:     splitNames(*acc),
+		Excludes: []string{*out},
+	}
+	src, err := Generate(cfg)
+	if err.IsNotOk() {
+		printError(err.Reason(), err.Error())
+		return 1
+	}
+
+	if e := os.WriteFile(filepath.Join(*dir, *out), src, 0o644); e != nil {
+		fmt.Fprintln(os.Stderr, "sabigen:", e)
+		return 1
+	}
+	return 0
+}
+
+func printError(reason any, msg string) {
+	switch r := reason.(type) {
+	case UnprovidedMethods:
+		for _, m := range r.Methods {
+			fmt.Fprintf(os.Stderr, "sabigen: %s is provided by no DataAcc\n", m)
+		}
+	case AmbiguousMethods:
+		for _, m := range r.Methods {
+			fmt.Fprintf(os.Stderr, "sabigen: %s is provided by more than one DataAcc\n", m)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "sabigen:", msg)
+	}
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func kebabCase(s string) string {
+	var b strings.Builder
+	rs := []rune(s)
+	for i, r := range rs {
+		if 'A' <= r && r <= 'Z' {
+			if i > 0 && (rs[i-1] < 'A' || rs[i-1] > 'Z' ||
+				(i+1 < len(rs) && 'a' <= rs[i+1] && rs[i+1] <= 'z')) {
+				b.WriteByte('-')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
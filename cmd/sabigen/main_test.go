@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("write output file", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"logic.go", "data-acc.go"} {
+			b, e := os.ReadFile(filepath.Join("testdata/app", name))
+			assert.Nil(t, e)
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, name), b, 0o644))
+		}
+
+		code := run([]string{"-dir", dir, "-hub", "MyDataHub", "-data", "MyData, OtherData",
+			"-acc", "GettingDataAcc,SettingDataAcc"})
+		assert.Equal(t, code, 0)
+
+		actual, e := os.ReadFile(filepath.Join(dir, "my-data-hub_gen.go"))
+		assert.Nil(t, e)
+		expected, e := os.ReadFile("testdata/my-data-hub_gen.go.golden")
+		assert.Nil(t, e)
+		assert.Equal(t, string(actual), string(expected))
+
+		code = run([]string{"-dir", dir, "-hub", "MyDataHub", "-data", "MyData", "-o", "hub.go"})
+		assert.Equal(t, code, 0)
+		_, e = os.Stat(filepath.Join(dir, "hub.go"))
+		assert.Nil(t, e)
+	})
+
+	t.Run("missing flags", func(t *testing.T) {
+		assert.Equal(t, run([]string{"-hub", "MyDataHub"}), 2)
+		assert.Equal(t, run([]string{"-unknown"}), 2)
+	})
+
+	t.Run("unprovided methods", func(t *testing.T) {
+		code := run([]string{"-dir", "testdata/app", "-hub", "MyDataHub", "-data", "BrokenData"})
+		assert.Equal(t, code, 1)
+	})
+}
+
+func TestKebabCase(t *testing.T) {
+	assert.Equal(t, kebabCase("MyDataHub"), "my-data-hub")
+	assert.Equal(t, kebabCase("HTTPDataHub"), "http-data-hub")
+	assert.Equal(t, kebabCase("hub"), "hub")
+}
+
+func TestSplitNames(t *testing.T) {
+	assert.Equal(t, splitNames(" A, B ,,C"), []string{"A", "B", "C"})
+	assert.Nil(t, splitNames(""))
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type GettingDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da *GettingDataAcc) GetText() (string, errs.Err) {
+	return "output text", errs.Ok()
+}
+
+func (da *GettingDataAcc) Count() int {
+	return 1
+}
+
+type SettingDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da *SettingDataAcc) SetText(text string) errs.Err {
+	return errs.Ok()
+}
+
+type AnotherGettingDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da AnotherGettingDataAcc) Purge() errs.Err {
+	return errs.Ok()
+}
+
+type NotDataAcc struct {
+	Name string
+}
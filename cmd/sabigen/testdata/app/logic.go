@@ -0,0 +1,34 @@
+package app
+
+import "github.com/sttk/errs"
+
+type TextGetter interface {
+	GetText() (string, errs.Err)
+}
+
+type MyData interface {
+	TextGetter
+	SetText(text string) errs.Err
+}
+
+type OtherData interface {
+	Count() int
+}
+
+type BrokenData interface {
+	GetText() (string, errs.Err)
+	Delete() errs.Err
+	Purge() errs.Err
+}
+
+type ForeignData interface {
+	error
+}
+
+func MyLogic(data MyData) errs.Err {
+	text, err := data.GetText()
+	if err.IsNotOk() {
+		return err
+	}
+	return data.SetText(text)
+}
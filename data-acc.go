@@ -5,6 +5,8 @@
 package sabi
 
 import (
+	"reflect"
+
 	"github.com/sttk/errs"
 )
 
@@ -15,4 +17,7 @@ import (
 // underlying transaction coordinator.
 type DataAcc interface {
 	getDataConn(name, dataConnType string) (DataConn, errs.Err)
+	getDataConnByType(
+		dataConnType string, target reflect.Type, match func(DataConn) bool,
+	) (DataConn, errs.Err)
 }
@@ -5,6 +5,8 @@
 package sabi
 
 import (
+	"time"
+
 	"github.com/sttk/errs"
 )
 
@@ -34,8 +36,22 @@ type /* error reasons */ (
 	FailToPostCommitDataConn struct {
 		Errors []ErrEntry
 	}
+
+	// FailToCompleteRunDataConn represents an error reason indicating that one or more data
+	// connections failed in the actions they take when the logic executed by Run succeeds, such
+	// as publishing the output of an IoDataSrc.
+	// It contains a list of individual connection errors for diagnosis.
+	FailToCompleteRunDataConn struct {
+		Errors []ErrEntry
+	}
 )
 
+// runCompleter is implemented by data connections which take an action when the logic executed
+// by Run succeeds, in place of PostCommit which Run does not call.
+type runCompleter interface {
+	completeRun(ag *AsyncGroup) errs.Err
+}
+
 // DataConn is an interface representing a database or external resource connection
 // that participates in transaction management. It defines methods for managing the
 // lifecycle of a transaction (pre-commit, commit, post-commit, rollback) as well as
@@ -77,15 +93,57 @@ type DataConn interface {
 	Close()
 }
 
+// DataConnWrapper is an interface implemented by a DataConn which decorates another DataConn,
+// for example to inject faults or to record calls in tests.
+//
+// GetDataConn and GetDataConnByType unwrap such a connection repeatedly until they find one of
+// the requested type, so DataAcc implementations keep working with the decorated connection
+// while the transaction lifecycle methods still go through the decorator.
+type DataConnWrapper interface {
+	DataConn
+
+	// Unwrap returns the decorated DataConn.
+	Unwrap() DataConn
+}
+
+func castDataConn[C any](dc DataConn) (C, bool) {
+	for {
+		if c, ok := dc.(C); ok {
+			return c, true
+		}
+		w, ok := dc.(DataConnWrapper)
+		if !ok {
+			return *new(C), false
+		}
+		dc = w.Unwrap()
+	}
+}
+
 type dataConnContainer struct {
-	name string
-	conn DataConn
+	name   string
+	conn   DataConn
+	ref    *dataSrcRef
+	leakId uint64
+}
+
+func (cont *dataConnContainer) close() {
+	cont.conn.Close()
+	cont.ref.release()
+	globalLeakTracker.untrack(cont.leakId)
 }
 
 type dataConnManager struct {
 	list      []dataConnContainer
 	indexMap  map[string]int
 	committed bool
+	observer  *observer
+}
+
+type phaseRecord struct {
+	listIndex int
+	index     int
+	start     time.Time
+	duration  time.Duration
 }
 
 func newDataConnManager() dataConnManager {
@@ -142,6 +200,8 @@ func (mgr *dataConnManager) newFailureReports() []TxnFailureReport {
 }
 
 func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
+	var recs []phaseRecord
+
 	ag := AsyncGroup{}
 	ii := 0
 	for i := range mgr.list {
@@ -151,12 +211,16 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 		ag._name = mgr.list[i].name
 		ag._index = ii
 		ii++
-		if err := mgr.list[i].conn.PreCommit(&ag); err.IsNotOk() {
+		start := time.Now()
+		err := mgr.list[i].conn.PreCommit(&ag)
+		recs = mgr.record(recs, i, ag._index, start)
+		if err.IsNotOk() {
 			ag.addErr(ag._index, ag._name, err)
 			break
 		}
 	}
 	errors := ag.join()
+	mgr.notifyPhase(PhasePreCommit, recs, errors, nil)
 
 	if len(errors) > 0 {
 		for i := range errors {
@@ -166,6 +230,7 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 		return errs.New(FailToPreCommitDataConn{Errors: errors})
 	}
 
+	recs = recs[:0]
 	ag = AsyncGroup{}
 	ii = 0
 	for i := range mgr.list {
@@ -176,13 +241,17 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 		ag._index = ii
 		ii++
 		if !mgr.list[i].conn.IsCommitted() {
-			if err := mgr.list[i].conn.Commit(&ag); err.IsNotOk() {
+			start := time.Now()
+			err := mgr.list[i].conn.Commit(&ag)
+			recs = mgr.record(recs, i, ag._index, start)
+			if err.IsNotOk() {
 				ag.addErr(ag._index, ag._name, err)
 				break
 			}
 		}
 	}
 	errors = ag.join()
+	mgr.notifyPhase(PhaseCommit, recs, errors, nil)
 
 	if len(errors) > 0 {
 		for i := range errors {
@@ -194,6 +263,7 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 
 	mgr.committed = true
 
+	recs = recs[:0]
 	ag = AsyncGroup{}
 	ii = 0
 	for i := range mgr.list {
@@ -203,12 +273,16 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 		ag._name = mgr.list[i].name
 		ag._index = ii
 		ii++
-		if err := mgr.list[i].conn.PostCommit(&ag); err.IsNotOk() {
+		start := time.Now()
+		err := mgr.list[i].conn.PostCommit(&ag)
+		recs = mgr.record(recs, i, ag._index, start)
+		if err.IsNotOk() {
 			ag.addErr(ag._index, ag._name, err)
 			// don't break
 		}
 	}
 	errors = ag.join()
+	mgr.notifyPhase(PhasePostCommit, recs, errors, nil)
 
 	if len(errors) > 0 {
 		for i := range errors {
@@ -222,6 +296,8 @@ func (mgr *dataConnManager) commit(reports []TxnFailureReport) errs.Err {
 }
 
 func (mgr *dataConnManager) rollback(reports []TxnFailureReport) {
+	var recs []phaseRecord
+
 	ag := AsyncGroup{}
 	ii := 0
 	for i := range mgr.list {
@@ -240,7 +316,10 @@ func (mgr *dataConnManager) rollback(reports []TxnFailureReport) {
 		if mgr.committed {
 			continue
 		}
-		if err := mgr.list[i].conn.Rollback(&ag); err.IsNotOk() {
+		start := time.Now()
+		err := mgr.list[i].conn.Rollback(&ag)
+		recs = mgr.record(recs, i, ag._index, start)
+		if err.IsNotOk() {
 			ag.addErr(ag._index, ag._name, err)
 		} else {
 			reports[ag._index].Rollback.State = NoneByRolledBack
@@ -254,14 +333,91 @@ func (mgr *dataConnManager) rollback(reports []TxnFailureReport) {
 			reports[idx].Rollback = TxnFailureRollback{State: RollbackFailure, Err: errors[i].Err}
 		}
 	}
+	mgr.notifyPhase(PhaseRollback, recs, errors, reports)
 
+	recs = recs[:0]
 	ag = AsyncGroup{}
+	ii = 0
 	for i := range mgr.list {
 		if mgr.list[i].conn != nil {
+			start := time.Now()
 			mgr.list[i].conn.OnTxnFailure(&ag, reports)
+			recs = mgr.record(recs, i, ii, start)
+			ii++
 		}
 	}
 	_ = ag.join()
+	mgr.notifyPhase(PhaseOnTxnFailure, recs, nil, reports)
+}
+
+func (mgr *dataConnManager) record(
+	recs []phaseRecord, listIndex, index int, start time.Time,
+) []phaseRecord {
+	if !mgr.observer.isActive() {
+		return recs
+	}
+	return append(recs, phaseRecord{
+		listIndex: listIndex, index: index, start: start, duration: time.Since(start)})
+}
+
+func (mgr *dataConnManager) notifyPhase(
+	phase Phase, recs []phaseRecord, errors []ErrEntry, reports []TxnFailureReport,
+) {
+	for _, rec := range recs {
+		err := errs.Ok()
+		for i := range errors {
+			if errors[i].Index == rec.index {
+				err = errors[i].Err
+				break
+			}
+		}
+		var report *TxnFailureReport
+		if reports != nil {
+			report = &reports[rec.index]
+		}
+		conn := mgr.list[rec.listIndex].conn
+		mgr.observer.notify(Event{
+			Phase:        phase,
+			DataConnName: mgr.list[rec.listIndex].name,
+			DataConnType: typeNameOf(conn),
+			Start:        rec.start,
+			Duration:     rec.duration,
+			Err:          err,
+			Report:       report,
+		})
+	}
+}
+
+func (mgr *dataConnManager) completeRun() errs.Err {
+	ag := AsyncGroup{}
+	ii := 0
+	for i := range mgr.list {
+		if mgr.list[i].conn == nil {
+			continue
+		}
+		ag._name = mgr.list[i].name
+		ag._index = ii
+		ii++
+		for dc := mgr.list[i].conn; dc != nil; {
+			if rc, ok := dc.(runCompleter); ok {
+				if err := rc.completeRun(&ag); err.IsNotOk() {
+					ag.addErr(ag._index, ag._name, err)
+				}
+				break
+			}
+			w, ok := dc.(DataConnWrapper)
+			if !ok {
+				break
+			}
+			dc = w.Unwrap()
+		}
+	}
+	errors := ag.join()
+
+	if len(errors) > 0 {
+		return errs.New(FailToCompleteRunDataConn{Errors: errors})
+	}
+	return errs.Ok()
 }
 
 func (mgr *dataConnManager) close() {
@@ -269,8 +425,9 @@ func (mgr *dataConnManager) close() {
 
 	for i := len(mgr.list) - 1; i >= 0; i-- {
 		if mgr.list[i].conn != nil {
-			mgr.list[i].conn.Close()
+			mgr.list[i].close()
 		}
 	}
 	clear(mgr.list)
+	mgr.committed = false
 }
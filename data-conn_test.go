@@ -1312,3 +1312,41 @@ func TestDataConn(t *testing.T) {
 		assert.Nil(t, log)
 	})
 }
+
+type WrapperDataConn struct {
+	*BadDataConn
+	inner DataConn
+}
+
+func (dc *WrapperDataConn) Unwrap() DataConn {
+	return dc.inner
+}
+
+func TestCastDataConn(t *testing.T) {
+	t.Run("not wrapped", func(t *testing.T) {
+		dc := &MyDataConn{id: 1}
+		c, ok := castDataConn[*MyDataConn](dc)
+		assert.True(t, ok)
+		assert.Equal(t, c, dc)
+
+		_, ok = castDataConn[*BadDataConn](dc)
+		assert.False(t, ok)
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		dc := &MyDataConn{id: 1}
+		w := &WrapperDataConn{BadDataConn: &BadDataConn{}, inner: dc}
+		ww := &WrapperDataConn{BadDataConn: &BadDataConn{}, inner: w}
+
+		c, ok := castDataConn[*MyDataConn](ww)
+		assert.True(t, ok)
+		assert.Equal(t, c, dc)
+
+		c2, ok := castDataConn[*WrapperDataConn](ww)
+		assert.True(t, ok)
+		assert.Equal(t, c2, ww)
+
+		_, ok = castDataConn[*AsyncGroup](ww)
+		assert.False(t, ok)
+	})
+}
@@ -5,6 +5,14 @@
 package sabi
 
 import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/sttk/errs"
 )
 
@@ -54,6 +62,37 @@ type /* error reasons */ (
 		ToDataConnType   string
 	}
 
+	// NoDataConnImplements represents an error reason indicating that none of the data
+	// connections available in a DataHub implements the interface type requested by the caller.
+	NoDataConnImplements struct {
+		DataConnType string
+	}
+
+	// MultipleDataConnsImplement represents an error reason indicating that more than one data
+	// connection available in a DataHub implements the interface type requested by the caller,
+	// so that the connection to be used cannot be determined. Names holds the names of the data
+	// sources of those connections.
+	MultipleDataConnsImplement struct {
+		DataConnType string
+		Names        []string
+	}
+
+	// DuplicatedDataSrcNames represents an error reason indicating that more than one data source
+	// was registered with the same name in the same scope, either globally or in a DataHub.
+	// This error is reported only when name conflict detection is enabled or the duplicate
+	// policy is RejectDuplicates.
+	DuplicatedDataSrcNames struct {
+		Names []string
+	}
+
+	// ShadowedDataSrcNames represents an error reason indicating that names of local data sources
+	// registered to a DataHub are the same as names of global data sources, or that aliases of a
+	// DataHub are the same as names of data sources, so that the latter would be hidden.
+	// This error is reported only when name conflict detection is enabled.
+	ShadowedDataSrcNames struct {
+		Names []string
+	}
+
 	// FailToCastDataHub represents an error reason indicating that the provided DataHub instance
 	// could not be type-cast to the generic data access interface type required by the run or
 	// transaction logic.
@@ -64,15 +103,50 @@ type /* error reasons */ (
 )
 
 var (
-	globalDataSrcManager dataSrcManager = newDataSrcManager(false)
-	globalDataSrcsFixed  bool           = false
+	globalDataSrcManager  dataSrcManager  = newDataSrcManager(false)
+	globalDataSrcsReady   bool            = false
+	nameConflictDetection bool            = false
+	duplicatePolicy       DuplicatePolicy = KeepDuplicates
 )
 
+// globalDataSrcsFixed is set by Setup or the creation of the first DataHub, and is read by Replace
+// while DataHubs are created concurrently.
+var globalDataSrcsFixed atomic.Bool
+
+// globalDataSrcMutex guards the ready global data sources, which can be replaced by Replace while
+// DataHubs are created.
+var globalDataSrcMutex sync.RWMutex
+
+// SetDuplicatePolicy sets the policy applied when Uses or DataHub.Uses registers a data source
+// with the same name as an already registered one in the same scope.
+// The default policy is KeepDuplicates.
+// This function should be called before any data source is registered.
+func SetDuplicatePolicy(policy DuplicatePolicy) {
+	if !globalDataSrcsFixed.Load() {
+		duplicatePolicy = policy
+	}
+}
+
+// DetectNameConflicts enables or disables the detection of conflicts among names of data
+// sources. By default, a data source registered later with the same name as another silently
+// takes precedence.
+//
+// When the detection is enabled, Setup and SetupWithOrder fail with the reason
+// DuplicatedDataSrcNames if global data sources have duplicated names, and Run and Txn fail with
+// DuplicatedDataSrcNames if local data sources of a DataHub have duplicated names, or with
+// ShadowedDataSrcNames if local data sources or aliases of a DataHub hide other data sources.
+// This function should be called before Setup.
+func DetectNameConflicts(enabled bool) {
+	if !globalDataSrcsFixed.Load() {
+		nameConflictDetection = enabled
+	}
+}
+
 // Uses registers a global data source with a unique identifier. This registration must occur
 // before Setup is called, as global data sources are initialized during the Setup phase and
 // shared across DataHub instances.
 func Uses(name string, ds DataSrc) {
-	if !globalDataSrcsFixed {
+	if !globalDataSrcsFixed.Load() {
 		globalDataSrcManager.add(name, ds)
 	}
 }
@@ -81,14 +155,21 @@ func Uses(name string, ds DataSrc) {
 // prevent further registrations. If any data source setup fails, it shuts down all successfully
 // initialized data sources and returns an error wrapper.
 func Setup() errs.Err {
-	if !globalDataSrcsFixed {
-		globalDataSrcsFixed = true
+	if !globalDataSrcsFixed.Load() {
+		globalDataSrcsFixed.Store(true)
+		globalDataSrcManager.observer = newGlobalObserver()
+
+		if err := checkGlobalDataSrcNames(); err.IsNotOk() {
+			return err
+		}
 
 		errors := globalDataSrcManager.setup()
 		if len(errors) > 0 {
 			globalDataSrcManager.close()
 			return errs.New(FailToSetupGlobalDataSrcs{Errors: errors})
 		}
+
+		globalDataSrcsReady = true
 	}
 
 	return errs.Ok()
@@ -99,23 +180,47 @@ func Setup() errs.Err {
 // ones.
 // If initialization fails, it shuts down all successfully initialized data sources and returns an error.
 func SetupWithOrder(names ...string) errs.Err {
-	if !globalDataSrcsFixed {
-		globalDataSrcsFixed = true
+	if !globalDataSrcsFixed.Load() {
+		globalDataSrcsFixed.Store(true)
+		globalDataSrcManager.observer = newGlobalObserver()
+
+		if err := checkGlobalDataSrcNames(); err.IsNotOk() {
+			return err
+		}
 
 		errors := globalDataSrcManager.setupWithOrder(names)
 		if len(errors) > 0 {
 			globalDataSrcManager.close()
 			return errs.New(FailToSetupGlobalDataSrcs{Errors: errors})
 		}
+
+		globalDataSrcsReady = true
 	}
 
 	return errs.Ok()
 }
 
+func checkGlobalDataSrcNames() errs.Err {
+	if nameConflictDetection || duplicatePolicy == RejectDuplicates {
+		if names := globalDataSrcManager.duplicatedNames(); len(names) > 0 {
+			globalDataSrcManager.close()
+			return errs.New(DuplicatedDataSrcNames{Names: names})
+		}
+	}
+	return errs.Ok()
+}
+
 // Shutdown cleans up and closes all global data sources that were successfully initialized,
 // releasing resources like connection pools.
+//
+// A global data source from which DataConns are still open in running DataHubs is closed when the
+// last of them is closed, instead of being closed immediately. Use ShutdownWithTimeout to wait for
+// it.
+// If the leak detection is enabled by DetectLeaks, DataHubs and DataConns which are not closed
+// yet are reported to its handler.
 func Shutdown() {
-	globalDataSrcManager.close()
+	shutdown()
+	reportLeaks()
 }
 
 // DataHub defines the interface for a coordinator that manages the lifecycle of local data sources,
@@ -128,14 +233,33 @@ type DataHub interface {
 	// instance.
 	// This local data source is only visible within this hub's execution scope.
 	Uses(name string, ds DataSrc)
+	// Alias makes the name alias refer to the data source registered with the name name in this
+	// DataHub, so that data access code using alias can be pointed at another data source, for
+	// example a namespaced one, without changing the code.
+	Alias(alias, name string)
+	// AddListener registers a Listener which observes only this DataHub, in addition to the
+	// global Listeners.
+	AddListener(l Listener)
+	// SetLogger sets a *slog.Logger which receives log records only about this DataHub, in place
+	// of the one set by the global SetLogger. Passing nil disables logging for this DataHub.
+	SetLogger(logger *slog.Logger)
+	// SetTracer sets a Tracer which creates spans only about this DataHub, in place of the one
+	// set by the global SetTracer. Passing nil disables tracing for this DataHub.
+	SetTracer(tracer Tracer)
+	// SetTraceContext sets the context which carries the parent span of the spans created about
+	// this DataHub, for example the context of an inbound request, so that the spans of Run and
+	// Txn join the trace of the caller.
+	SetTraceContext(ctx context.Context)
 	// Disuses removes a registered local data source from this DataHub instance, or marks a global
 	// data source as ignored in this hub's context.
 	Disuses(name string)
 	// Close releases all local resources, connections, and data sources managed by this DataHub.
 	Close()
 
-	begin() errs.Err
+	begin(txn bool) errs.Err
+	completeRun(errs.Err) errs.Err
 	commitOrRollback(errs.Err) errs.Err
+	done(errs.Err) errs.Err
 	end()
 }
 
@@ -146,43 +270,61 @@ type dataHubImpl struct {
 	dataSrcMap          map[string]dataSrcContainer
 	dataConnManager     dataConnManager
 	dataConnMap         map[string]dataConnContainer
+	dataConnTypeMap     map[string]string
+	aliasMap            map[string]string
+	observer            observer
+	beginTime           time.Time
+	txn                 bool
+	result              errs.Err
 	fixed               bool
+	leakId              uint64
+
+	// The following fields make the retrieval of DataConns safe for goroutines sharing this hub.
+	// dataConnMutex guards dataConnMap, dataConnTypeMap and dataConnManager, and
+	// creatingMutexMap serializes the creation of DataConns per name.
+	dataConnMutex    sync.Mutex
+	creatingMutexMap map[string]*sync.Mutex
 }
 
 // NewDataHub creates and initializes a new DataHub instance populated with the currently
 // ready global data sources. The returned hub can be configured with additional local data sources
 // prior to executing logic.
 func NewDataHub() DataHub {
-	globalDataSrcsFixed = true
-
-	dsMap := make(map[string]dataSrcContainer, len(globalDataSrcManager.listReady))
-	globalDataSrcManager.copyDsReadyToMap(dsMap)
-
-	return &dataHubImpl{
-		localDataSrcManager: newDataSrcManager(true),
-		dataSrcMap:          dsMap,
-		dataConnManager:     newDataConnManager(),
-		dataConnMap:         make(map[string]dataConnContainer),
-		fixed:               false,
-	}
+	return newDataHubImpl(newDataConnManager())
 }
 
 // NewDataHubWithCommitOrder creates and initializes a new DataHub instance, specifying a sequence
 // in which its data connections should be committed. This helps ensure multi-resource consistency
 // when certain connections depend on the successful commit of others.
 func NewDataHubWithCommitOrder(names ...string) DataHub {
-	globalDataSrcsFixed = true
+	return newDataHubImpl(newDataConnManagerWithCommitOrder(names))
+}
+
+func newDataHubImpl(dcMgr dataConnManager) *dataHubImpl {
+	if !globalDataSrcsFixed.Load() {
+		globalDataSrcsFixed.Store(true)
+	}
 
+	globalDataSrcMutex.RLock()
 	dsMap := make(map[string]dataSrcContainer, len(globalDataSrcManager.listReady))
 	globalDataSrcManager.copyDsReadyToMap(dsMap)
+	globalDataSrcMutex.RUnlock()
 
-	return &dataHubImpl{
+	hub := &dataHubImpl{
 		localDataSrcManager: newDataSrcManager(true),
 		dataSrcMap:          dsMap,
-		dataConnManager:     newDataConnManagerWithCommitOrder(names),
+		dataConnManager:     dcMgr,
 		dataConnMap:         make(map[string]dataConnContainer),
+		dataConnTypeMap:     make(map[string]string),
+		aliasMap:            make(map[string]string),
+		creatingMutexMap:    make(map[string]*sync.Mutex),
+		observer:            newObserver(),
 		fixed:               false,
 	}
+	hub.localDataSrcManager.observer = &hub.observer
+	hub.dataConnManager.observer = &hub.observer
+	hub.leakId = globalLeakTracker.track(LeakedDataHub, "", "")
+	return hub
 }
 
 func (hub *dataHubImpl) Uses(name string, ds DataSrc) {
@@ -193,6 +335,46 @@ func (hub *dataHubImpl) Uses(name string, ds DataSrc) {
 	hub.localDataSrcManager.add(name, ds)
 }
 
+func (hub *dataHubImpl) Alias(alias, name string) {
+	if hub.fixed {
+		return
+	}
+
+	hub.aliasMap[alias] = name
+}
+
+func (hub *dataHubImpl) AddListener(l Listener) {
+	if hub.fixed {
+		return
+	}
+
+	hub.observer.listeners = append(hub.observer.listeners, l)
+}
+
+func (hub *dataHubImpl) SetLogger(logger *slog.Logger) {
+	if hub.fixed {
+		return
+	}
+
+	hub.observer.logger = logger
+}
+
+func (hub *dataHubImpl) SetTracer(tracer Tracer) {
+	if hub.fixed {
+		return
+	}
+
+	hub.observer.tracer = tracer
+}
+
+func (hub *dataHubImpl) SetTraceContext(ctx context.Context) {
+	if hub.fixed {
+		return
+	}
+
+	hub.observer.ctx = ctx
+}
+
 func (hub *dataHubImpl) Disuses(name string) {
 	if hub.fixed {
 		return
@@ -212,13 +394,41 @@ func (hub *dataHubImpl) Close() {
 		return
 	}
 	clear(hub.dataConnMap)
+	clear(hub.dataConnTypeMap)
 	hub.dataConnManager.close()
 	clear(hub.dataSrcMap)
+	clear(hub.aliasMap)
 	hub.localDataSrcManager.close()
+	globalLeakTracker.untrack(hub.leakId)
+	hub.leakId = 0
 }
 
-func (hub *dataHubImpl) begin() errs.Err {
+func (hub *dataHubImpl) begin(txn bool) errs.Err {
 	hub.fixed = true
+	hub.beginTime = time.Now()
+	hub.txn = txn
+	hub.result = errs.Ok()
+	hub.observer.startTrace(txn, hub.beginTime)
+
+	err := hub.setupLocalDataSrcs()
+
+	if hub.observer.isActive() {
+		hub.observer.notify(Event{
+			Phase: PhaseBegin, Start: hub.beginTime, Duration: time.Since(hub.beginTime), Err: err})
+	}
+
+	// Run and Txn don't call end when begin fails, so the hub is unfixed here to let Close
+	// release it.
+	if err.IsNotOk() {
+		hub.fixed = false
+	}
+	return err
+}
+
+func (hub *dataHubImpl) setupLocalDataSrcs() errs.Err {
+	if err := hub.checkDataSrcNames(); err.IsNotOk() {
+		return err
+	}
 
 	errors := hub.localDataSrcManager.setup()
 	if len(errors) > 0 {
@@ -229,19 +439,107 @@ func (hub *dataHubImpl) begin() errs.Err {
 	return errs.Ok()
 }
 
+func (hub *dataHubImpl) checkDataSrcNames() errs.Err {
+	if nameConflictDetection || duplicatePolicy == RejectDuplicates {
+		if names := hub.localDataSrcManager.duplicatedNames(); len(names) > 0 {
+			return errs.New(DuplicatedDataSrcNames{Names: names})
+		}
+	}
+
+	if !nameConflictDetection {
+		return errs.Ok()
+	}
+
+	names := hub.localDataSrcManager.shadowedNames(hub.dataSrcMap)
+
+	aliases := make([]string, 0, len(hub.aliasMap))
+	for alias := range hub.aliasMap {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		if _, ok := hub.dataSrcMap[alias]; ok {
+			names = append(names, alias)
+			continue
+		}
+		for i := range hub.localDataSrcManager.listUnready {
+			cont := &hub.localDataSrcManager.listUnready[i]
+			if cont.name == alias && cont.ds != nil {
+				names = append(names, alias)
+				break
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		return errs.New(ShadowedDataSrcNames{Names: names})
+	}
+	return errs.Ok()
+}
+
+func (hub *dataHubImpl) completeRun(err errs.Err) errs.Err {
+	if err.IsOk() {
+		err = hub.dataConnManager.completeRun()
+	}
+	return hub.done(err)
+}
+
 func (hub *dataHubImpl) commitOrRollback(err errs.Err) errs.Err {
-	return hub.dataConnManager.commitOrRollback(err)
+	return hub.done(hub.dataConnManager.commitOrRollback(err))
+}
+
+func (hub *dataHubImpl) done(err errs.Err) errs.Err {
+	hub.result = err
+	return err
 }
 
 func (hub *dataHubImpl) end() {
 	clear(hub.dataConnMap)
+	clear(hub.dataConnTypeMap)
+	clear(hub.creatingMutexMap)
 	hub.dataConnManager.close()
 
+	if hub.observer.isActive() {
+		hub.observer.notify(Event{
+			Phase:    PhaseEnd,
+			Start:    hub.beginTime,
+			Duration: time.Since(hub.beginTime),
+			Err:      hub.result,
+			Txn:      hub.txn,
+		})
+	}
+
 	hub.fixed = false
 }
 
 func (hub *dataHubImpl) getDataConn(name string, dataConnType string) (DataConn, errs.Err) {
+	if target, ok := hub.aliasMap[name]; ok {
+		name = target
+	}
+	return hub.getDataConnOfName(name, dataConnType)
+}
+
+func (hub *dataHubImpl) getDataConnOfName(name string, dataConnType string) (DataConn, errs.Err) {
+	hub.dataConnMutex.Lock()
 	dcCont, ok := hub.dataConnMap[name]
+	creatingMutex := hub.creatingMutexMap[name]
+	if !ok && creatingMutex == nil {
+		creatingMutex = new(sync.Mutex)
+		hub.creatingMutexMap[name] = creatingMutex
+	}
+	hub.dataConnMutex.Unlock()
+	if ok {
+		return dcCont.conn, errs.Ok()
+	}
+
+	creatingMutex.Lock()
+	defer creatingMutex.Unlock()
+
+	// Another goroutine may have created the DataConn while waiting for the lock.
+	hub.dataConnMutex.Lock()
+	dcCont, ok = hub.dataConnMap[name]
+	hub.dataConnMutex.Unlock()
 	if ok {
 		return dcCont.conn, errs.Ok()
 	}
@@ -251,18 +549,141 @@ func (hub *dataHubImpl) getDataConn(name string, dataConnType string) (DataConn,
 		return nil, errs.New(NoDataSrcToCreateDataConn{Name: name, DataConnType: dataConnType})
 	}
 
-	dc, err := dsCont.ds.CreateDataConn()
+	dcCont, err := hub.createDataConn(dsCont, dataConnType)
 	if err.IsNotOk() {
-		return nil, errs.New(FailToCreateDataConn{Name: name, DataConnType: dataConnType}, err)
+		return nil, err
 	}
-	if dc == nil {
-		return nil, errs.New(CreatedDataConnIsNil{Name: name, DataConnType: dataConnType})
+
+	hub.dataConnMutex.Lock()
+	hub.addDataConn(dcCont)
+	hub.dataConnMutex.Unlock()
+	return dcCont.conn, errs.Ok()
+}
+
+func (hub *dataHubImpl) getDataConnByType(
+	dataConnType string, target reflect.Type, match func(DataConn) bool,
+) (DataConn, errs.Err) {
+	hub.dataConnMutex.Lock()
+
+	if name, ok := hub.dataConnTypeMap[dataConnType]; ok {
+		if dcCont, ok := hub.dataConnMap[name]; ok {
+			hub.dataConnMutex.Unlock()
+			return dcCont.conn, errs.Ok()
+		}
 	}
 
-	dcCont = dataConnContainer{name: name, conn: dc}
-	hub.dataConnMap[name] = dcCont
+	names := make([]string, 0, len(hub.dataSrcMap))
+	for name := range hub.dataSrcMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// DataConns are never created to examine data sources, so only DataConns already created and
+	// data sources declaring the types of their DataConns are candidates.
+	matchedNames := make([]string, 0, 1)
+	var matchedConn DataConn
+	for _, name := range names {
+		if dcCont, ok := hub.dataConnMap[name]; ok {
+			if match(dcCont.conn) {
+				matchedNames = append(matchedNames, name)
+				matchedConn = dcCont.conn
+			}
+			continue
+		}
+		if t, ok := declaredDataConnTypeOf(hub.dataSrcMap[name].ds); ok {
+			if dataConnTypeMatches(t, target) {
+				matchedNames = append(matchedNames, name)
+			}
+		}
+	}
+
+	hub.dataConnMutex.Unlock()
+
+	if len(matchedNames) == 0 {
+		return nil, errs.New(NoDataConnImplements{DataConnType: dataConnType})
+	}
+	if len(matchedNames) > 1 {
+		return nil, errs.New(MultipleDataConnsImplement{
+			DataConnType: dataConnType, Names: matchedNames})
+	}
+
+	name := matchedNames[0]
+	if matchedConn == nil {
+		dc, err := hub.getDataConnOfName(name, dataConnType)
+		if err.IsNotOk() {
+			return nil, err
+		}
+		if !match(dc) {
+			return nil, errs.New(NoDataConnImplements{DataConnType: dataConnType})
+		}
+		matchedConn = dc
+	}
+
+	hub.dataConnMutex.Lock()
+	hub.dataConnTypeMap[dataConnType] = name
+	hub.dataConnMutex.Unlock()
+	return matchedConn, errs.Ok()
+}
+
+func (hub *dataHubImpl) addDataConn(dcCont dataConnContainer) {
+	hub.dataConnMap[dcCont.name] = dcCont
 	hub.dataConnManager.add(dcCont)
+}
+
+func (hub *dataHubImpl) createDataConn(
+	dsCont dataSrcContainer, dataConnType string,
+) (dataConnContainer, errs.Err) {
+	dsCont, ok := hub.acquireDataSrc(dsCont)
+	if !ok {
+		return dataConnContainer{}, errs.New(
+			NoDataSrcToCreateDataConn{Name: dsCont.name, DataConnType: dataConnType})
+	}
+
+	dc, err := hub.newDataConn(dsCont, dataConnType)
+	if err.IsNotOk() {
+		dsCont.ref.release()
+		return dataConnContainer{}, err
+	}
+	leakId := globalLeakTracker.track(LeakedDataConn, dsCont.name, typeNameOf(dc))
+	return dataConnContainer{name: dsCont.name, conn: dc, ref: dsCont.ref, leakId: leakId}, errs.Ok()
+}
+
+func (hub *dataHubImpl) newDataConn(
+	dsCont dataSrcContainer, dataConnType string,
+) (DataConn, errs.Err) {
+	if err := hub.setupLazyDataSrc(dsCont); err.IsNotOk() {
+		return nil, err
+	}
 
+	if !hub.observer.isActive() {
+		return createDataConn(dsCont, dataConnType)
+	}
+
+	start := time.Now()
+	dc, err := createDataConn(dsCont, dataConnType)
+	if dc != nil {
+		dataConnType = typeNameOf(dc)
+	}
+	hub.observer.notify(Event{
+		Phase:        PhaseGetDataConn,
+		DataConnName: dsCont.name,
+		DataConnType: dataConnType,
+		Start:        start,
+		Duration:     time.Since(start),
+		Err:          err,
+	})
+	return dc, err
+}
+
+func createDataConn(dsCont dataSrcContainer, dataConnType string) (DataConn, errs.Err) {
+	dc, err := dsCont.ds.CreateDataConn()
+	if err.IsNotOk() {
+		return nil, errs.New(
+			FailToCreateDataConn{Name: dsCont.name, DataConnType: dataConnType}, err)
+	}
+	if dc == nil {
+		return nil, errs.New(CreatedDataConnIsNil{Name: dsCont.name, DataConnType: dataConnType})
+	}
 	return dc, errs.Ok()
 }
 
@@ -270,6 +691,8 @@ func (hub *dataHubImpl) getDataConn(name string, dataConnType string) (DataConn,
 // container.
 // It searches the container's DataHub, instantiating the connection from the registered data source
 // if it does not yet exist, and casts it to the expected interface type.
+// If the connection is a DataConnWrapper, it is unwrapped until a connection of the expected type
+// is found.
 func GetDataConn[C DataConn](data any, name string) (C, errs.Err) {
 	hub := data.(DataAcc)
 
@@ -280,7 +703,7 @@ func GetDataConn[C DataConn](data any, name string) (C, errs.Err) {
 		return *new(C), err
 	}
 
-	c, ok := dc.(C)
+	c, ok := castDataConn[C](dc)
 	if !ok {
 		return *new(C), errs.New(FailToCastDataConn{
 			Name: name, FromDataConnType: typeNameOf(dc), ToDataConnType: toType})
@@ -289,11 +712,45 @@ func GetDataConn[C DataConn](data any, name string) (C, errs.Err) {
 	return c, errs.Ok()
 }
 
+// GetDataConnByType retrieves the single active connection which implements the interface
+// type C from the provided data container, without specifying the name of its data source.
+//
+// This allows data access code to ask for a capability, such as "something that implements
+// KeyValueStore", instead of coupling itself to registration names.
+// It examines the data sources available in the container's DataHub, and returns an error with the
+// reason NoDataConnImplements if none of them provides C, or MultipleDataConnsImplement if more
+// than one does.
+//
+// The data sources examined are those whose DataConns have already been created in the current
+// Run or Txn and those which declare the types of their DataConns with DataConnTypeDeclarer.
+// No DataConn is created only to examine a data source, so a data source which neither declares
+// the type nor has created a DataConn yet is not found. A DataConn is created only for the data
+// source found, which is set up at that point if it is registered with Lazy.
+// Once resolved, the result is cached until the end of the current Run or Txn.
+func GetDataConnByType[C any](data any) (C, errs.Err) {
+	hub := data.(DataAcc)
+
+	toType := typeNameOfTypeParam[C]()
+
+	dc, err := hub.getDataConnByType(toType, reflect.TypeFor[C](), func(dc DataConn) bool {
+		_, ok := castDataConn[C](dc)
+		return ok
+	})
+	if err.IsNotOk() {
+		return *new(C), err
+	}
+
+	c, _ := castDataConn[C](dc)
+	return c, errs.Ok()
+}
+
 // Run executes a non-transactional business logic function using the provided DataHub.
 // It manages the hub's lifecycle by starting its local data sources before running the logic,
 // and ensures proper resource cleanup upon completion. It returns an error if setup or the logic
 // fails.
-func Run[D any](hub DataHub, logic func(D) errs.Err) errs.Err {
+// The logic is invoked through the global interceptors and the interceptors passed to this
+// function.
+func Run[D any](hub DataHub, logic func(D) errs.Err, interceptors ...Interceptor) errs.Err {
 	data, ok := hub.(D)
 	if !ok {
 		fromType := typeNameOf(&hub)[1:]
@@ -301,19 +758,25 @@ func Run[D any](hub DataHub, logic func(D) errs.Err) errs.Err {
 		return errs.New(FailToCastDataHub{FromType: fromType, ToType: toType})
 	}
 
-	err := hub.begin()
+	return runLogic(hub, func() errs.Err { return logic(data) }, interceptors)
+}
+
+func runLogic(hub DataHub, logic func() errs.Err, interceptors []Interceptor) errs.Err {
+	err := hub.begin(false)
 	if err.IsNotOk() {
 		return err
 	}
 	defer hub.end()
 
-	return logic(data)
+	return hub.completeRun(invokeLogic(hub, logic, interceptors))
 }
 
 // Txn executes a transactional business logic function using the provided DataHub.
 // It manages the hub's lifecycle, starting data sources, running the logic, and automatically
 // committing the changes if the logic succeeds, or rolling back if an error occurs.
-func Txn[D any](hub DataHub, logic func(D) errs.Err) errs.Err {
+// The logic is invoked through the global interceptors and the interceptors passed to this
+// function.
+func Txn[D any](hub DataHub, logic func(D) errs.Err, interceptors ...Interceptor) errs.Err {
 	data, ok := hub.(D)
 	if !ok {
 		fromType := typeNameOf(&hub)[1:]
@@ -321,12 +784,38 @@ func Txn[D any](hub DataHub, logic func(D) errs.Err) errs.Err {
 		return errs.New(FailToCastDataHub{FromType: fromType, ToType: toType})
 	}
 
-	err := hub.begin()
+	return txnLogic(hub, func() errs.Err { return logic(data) }, interceptors)
+}
+
+func txnLogic(hub DataHub, logic func() errs.Err, interceptors []Interceptor) errs.Err {
+	err := hub.begin(true)
 	if err.IsNotOk() {
 		return err
 	}
 	defer hub.end()
 
-	err = logic(data)
+	err = invokeLogic(hub, logic, interceptors)
 	return hub.commitOrRollback(err)
 }
+
+// RunOf executes a non-transactional business logic function like Run, but the hub is passed to
+// the logic as its concrete type H without a runtime type assertion, so this function never fails
+// with FailToCastDataHub.
+//
+// By writing a logic function generically with its data interface as the constraint, such as
+// `func MyLogic[D MyData](data D) errs.Err`, RunOf(hub, MyLogic) instantiates the logic with the
+// concrete type of the hub, and a hub which does not implement the data interface becomes a
+// compile error. For this, the constructor of the hub should return its concrete type instead of
+// DataHub.
+func RunOf[H DataHub](hub H, logic func(H) errs.Err, interceptors ...Interceptor) errs.Err {
+	return runLogic(hub, func() errs.Err { return logic(hub) }, interceptors)
+}
+
+// TxnOf executes a transactional business logic function like Txn, but the hub is passed to the
+// logic as its concrete type H without a runtime type assertion, so this function never fails
+// with FailToCastDataHub.
+//
+// See RunOf for how to make a mismatched hub a compile error.
+func TxnOf[H DataHub](hub H, logic func(H) errs.Err, interceptors ...Interceptor) errs.Err {
+	return txnLogic(hub, func() errs.Err { return logic(hub) }, interceptors)
+}
@@ -3,6 +3,7 @@ package sabi
 import (
 	"container/list"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -146,6 +147,43 @@ func (dc *BadDataConn) Rollback(ag *AsyncGroup) errs.Err
 func (dc *BadDataConn) OnTxnFailure(ag *AsyncGroup, reports []TxnFailureReport) {}
 func (dc *BadDataConn) Close()                                                  {}
 
+type BadDataSrc struct {
+	logger *list.List
+}
+
+func (ds *BadDataSrc) Setup(ag *AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *BadDataSrc) Close()                        {}
+func (ds *BadDataSrc) CreateDataConn() (DataConn, errs.Err) {
+	if ds.logger != nil {
+		ds.logger.PushBack("BadDataSrc#CreateDataConn")
+	}
+	return &BadDataConn{}, errs.Ok()
+}
+
+type DeclaringDataSrc struct {
+	*MyDataSrc
+}
+
+func (ds *DeclaringDataSrc) DataConnType() reflect.Type {
+	return reflect.TypeFor[*MyDataConn]()
+}
+
+type DeclaringBadDataSrc struct {
+	BadDataSrc
+}
+
+func (ds *DeclaringBadDataSrc) DataConnType() reflect.Type {
+	return reflect.TypeFor[*BadDataConn]()
+}
+
+type KeyValueStore interface {
+	Get(key string) string
+}
+
+func (dc *MyDataConn) Get(key string) string {
+	return fmt.Sprintf("%s of %d", key, dc.id)
+}
+
 func countDs(list []dataSrcContainer) int {
 	n := 0
 	for _, cont := range list {
@@ -206,7 +244,7 @@ func TestDataHub(t *testing.T) {
 		assert.Empty(t, hubImpl.dataConnMap)
 		assert.False(t, hubImpl.fixed)
 
-		assert.True(t, hub.begin().IsOk())
+		assert.True(t, hub.begin(false).IsOk())
 
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 0)
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listReady), 2)
@@ -236,7 +274,7 @@ func TestDataHub(t *testing.T) {
 		assert.Empty(t, hubImpl.dataConnMap)
 		assert.False(t, hubImpl.fixed)
 
-		assert.True(t, hub.begin().IsOk())
+		assert.True(t, hub.begin(false).IsOk())
 
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 0)
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listReady), 1)
@@ -345,7 +383,7 @@ func TestDataHub(t *testing.T) {
 		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
 		hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
 
-		assert.True(t, hub.begin().IsOk())
+		assert.True(t, hub.begin(false).IsOk())
 
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 0)
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listReady), 2)
@@ -416,7 +454,7 @@ func TestDataHub(t *testing.T) {
 		hub := NewDataHub()
 		defer hub.Close()
 
-		assert.True(t, hub.begin().IsOk())
+		assert.True(t, hub.begin(false).IsOk())
 
 		hubImpl := hub.(*dataHubImpl)
 		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 0)
@@ -460,7 +498,7 @@ func TestDataHub(t *testing.T) {
 			assert.Empty(t, hubImpl.dataConnMap)
 			assert.False(t, hubImpl.fixed)
 
-			assert.True(t, hub.begin().IsOk())
+			assert.True(t, hub.begin(false).IsOk())
 
 			assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 0)
 			assert.Equal(t, countDs(hubImpl.localDataSrcManager.listReady), 2)
@@ -516,7 +554,7 @@ func TestDataHub(t *testing.T) {
 			assert.Empty(t, hubImpl.dataConnMap)
 			assert.False(t, hubImpl.fixed)
 
-			err := hub.begin()
+			err := hub.begin(false)
 			defer hub.end()
 
 			switch rsn := err.Reason().(type) {
@@ -1370,240 +1408,748 @@ func TestDataHub(t *testing.T) {
 		log = log.Next()
 		assert.Nil(t, log)
 	})
-}
-
-func ResetGlobals() {
-	globalDataSrcsFixed = false
-	globalDataSrcManager.close()
-}
-
-func TestGlobals(t *testing.T) {
-	t.Run("Uses and Setup, and ok", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type", func(t *testing.T) {
 		logger := list.New()
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
 
-		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+			hub.Uses("foo", &DeclaringDataSrc{NewMyDataSrc(1, Failure_None, logger)})
+			hub.Uses("bar", &BadDataSrc{logger: logger})
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 1)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
+			err := Txn(hub, func(data any) errs.Err {
+				logger.PushBack("execute logic")
+				kvs, err := GetDataConnByType[KeyValueStore](data)
+				assert.True(t, err.IsOk())
+				assert.Equal(t, kvs.Get("k"), "k of 1")
 
-		func() {
-			err := Setup()
-			defer Shutdown()
-			assert.True(t, err.IsOk())
+				kvs2, err := GetDataConnByType[KeyValueStore](data)
+				assert.True(t, err.IsOk())
+				assert.Same(t, kvs, kvs2)
 
-			assert.True(t, globalDataSrcsFixed)
-			assert.False(t, globalDataSrcManager.local)
-			assert.Len(t, globalDataSrcManager.listUnready, 0)
-			assert.Len(t, globalDataSrcManager.listReady, 1)
+				dc, err := GetDataConn[*MyDataConn](data, "foo")
+				assert.True(t, err.IsOk())
+				assert.Same(t, kvs, dc)
+				return errs.Ok()
+			})
+			assert.True(t, err.IsOk())
 		}()
 
 		log := logger.Front()
 		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
 		log = log.Next()
+		assert.Equal(t, log.Value, "execute logic")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
 		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
 		log = log.Next()
 		assert.Nil(t, log)
 	})
 
-	t.Run("Uses and Setup, but fail", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type which is already created", func(t *testing.T) {
 		logger := list.New()
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
-		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
-
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 1)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
 		func() {
-			err := Setup()
-			defer Shutdown()
-			assert.True(t, err.IsNotOk())
+			hub := NewDataHub()
+			defer hub.Close()
 
-			switch rsn := err.Reason().(type) {
-			case FailToSetupGlobalDataSrcs:
-				assert.Len(t, rsn.Errors, 1)
-				assert.Equal(t, rsn.Errors[0].Index, 0)
-				assert.Equal(t, rsn.Errors[0].Name, "foo")
-				assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
-			default:
-				assert.Fail(t, err.Error())
-			}
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
 
-			assert.True(t, globalDataSrcsFixed)
-			assert.False(t, globalDataSrcManager.local)
-			assert.Len(t, globalDataSrcManager.listUnready, 0)
-			assert.Len(t, globalDataSrcManager.listReady, 0)
+			err := Run(hub, func(data any) errs.Err {
+				dc, err := GetDataConn[*MyDataConn](data, "foo")
+				assert.True(t, err.IsOk())
+				kvs, err := GetDataConnByType[KeyValueStore](data)
+				assert.True(t, err.IsOk())
+				assert.Same(t, kvs, dc)
+				return errs.Ok()
+			})
+			assert.True(t, err.IsOk())
 		}()
 
 		log := logger.Front()
-		assert.Equal(t, log.Value, "MyDataSrc#Setup 1 failed")
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
 		log = log.Next()
 		assert.Nil(t, log)
 	})
 
-	t.Run("Uses and Setup, but already fixed before", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type but no data conn implements", func(t *testing.T) {
 		logger := list.New()
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
-		err := Setup()
-		assert.True(t, err.IsOk())
-
-		assert.True(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
-		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
 
-		assert.True(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+			hub.Uses("bar", &DeclaringBadDataSrc{BadDataSrc{logger: logger}})
 
-		Shutdown()
+			err := Txn(hub, func(data any) errs.Err {
+				_, err := GetDataConnByType[KeyValueStore](data)
+				return err
+			})
+			switch rsn := err.Reason().(type) {
+			case NoDataConnImplements:
+				assert.Equal(t, rsn.DataConnType, "sabi.KeyValueStore")
+			default:
+				assert.Fail(t, err.Error())
+			}
+		}()
 
 		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
 		assert.Nil(t, log)
 	})
 
-	t.Run("Uses and SetupWithOrder, and ok", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type but multiple data conns implement", func(t *testing.T) {
 		logger := list.New()
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
-		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
-		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
-
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 2)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
 		func() {
-			err := SetupWithOrder("bar", "foo")
-			defer Shutdown()
-			assert.True(t, err.IsOk())
+			hub := NewDataHub()
+			defer hub.Close()
 
-			assert.True(t, globalDataSrcsFixed)
-			assert.False(t, globalDataSrcManager.local)
-			assert.Len(t, globalDataSrcManager.listUnready, 0)
-			assert.Len(t, globalDataSrcManager.listReady, 2)
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+			hub.Uses("bar", &DeclaringDataSrc{NewMyDataSrc(2, Failure_None, logger)})
+
+			err := Txn(hub, func(data any) errs.Err {
+				if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+					return err
+				}
+				_, err := GetDataConnByType[KeyValueStore](data)
+				return err
+			})
+			switch rsn := err.Reason().(type) {
+			case MultipleDataConnsImplement:
+				assert.Equal(t, rsn.DataConnType, "sabi.KeyValueStore")
+				assert.Equal(t, rsn.Names, []string{"bar", "foo"})
+			default:
+				assert.Fail(t, err.Error())
+			}
 		}()
 
 		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
 		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
 		log = log.Next()
-		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
 		log = log.Next()
-		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		assert.Equal(t, log.Value, "MyDataConn#Rollback 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#OnTxnFailure 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
 		log = log.Next()
 		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
 		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
 		assert.Nil(t, log)
 	})
 
-	t.Run("Uses and SetupWithOrder, but fail", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type but fail to create data conn", func(t *testing.T) {
 		logger := list.New()
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
-		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
-		Uses("bar", NewMyDataSrc(2, Failure_Setup, logger))
-
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 2)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
-
 		func() {
-			err := SetupWithOrder("bar", "foo")
-			defer Shutdown()
-			assert.True(t, err.IsNotOk())
+			hub := NewDataHub()
+			defer hub.Close()
 
+			hub.Uses("bar", &DeclaringDataSrc{NewMyDataSrc(2, Failure_CreateDataConn, logger)})
+
+			err := Txn(hub, func(data any) errs.Err {
+				_, err := GetDataConnByType[KeyValueStore](data)
+				return err
+			})
 			switch rsn := err.Reason().(type) {
-			case FailToSetupGlobalDataSrcs:
-				assert.Len(t, rsn.Errors, 1)
-				assert.Equal(t, rsn.Errors[0].Index, 0)
-				assert.Equal(t, rsn.Errors[0].Name, "bar")
-				assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
+			case FailToCreateDataConn:
+				assert.Equal(t, rsn.Name, "bar")
 			default:
 				assert.Fail(t, err.Error())
 			}
-
-			assert.True(t, globalDataSrcsFixed)
-			assert.False(t, globalDataSrcManager.local)
-			assert.Len(t, globalDataSrcManager.listUnready, 0)
-			assert.Len(t, globalDataSrcManager.listReady, 0)
 		}()
 
 		log := logger.Front()
-		assert.Equal(t, log.Value, "MyDataSrc#Setup 2 failed")
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2 failed")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
 		log = log.Next()
 		assert.Nil(t, log)
 	})
 
-	t.Run("Uses and SetupWithOrder, but already fixed before", func(t *testing.T) {
-		ResetGlobals()
-		defer ResetGlobals()
-
+	t.Run("get data conn by type notifies only the data conn created", func(t *testing.T) {
 		logger := list.New()
+		var names []string
 
-		assert.False(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
 
-		err := SetupWithOrder("bar", "foo")
-		assert.True(t, err.IsOk())
+			hub.Uses("foo", &DeclaringDataSrc{NewMyDataSrc(1, Failure_None, logger)})
+			hub.Uses("bar", &BadDataSrc{logger: logger})
+			hub.Uses("baz", Lazy(NewMyDataSrc(3, Failure_None, logger)))
+			hub.AddListener(ListenerFunc(func(ev Event) {
+				if ev.Phase == PhaseGetDataConn {
+					names = append(names, ev.DataConnName)
+				}
+			}))
 
-		assert.True(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
-		assert.Len(t, globalDataSrcManager.listReady, 0)
+			err := Run(hub, func(data any) errs.Err {
+				_, err := GetDataConnByType[KeyValueStore](data)
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
 
-		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+		assert.Equal(t, names, []string{"foo"})
 
-		assert.True(t, globalDataSrcsFixed)
-		assert.False(t, globalDataSrcManager.local)
-		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("get data conn by type declared by data sources", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", &DeclaringDataSrc{NewMyDataSrc(1, Failure_None, logger)})
+			hub.Uses("bar", &DeclaringBadDataSrc{BadDataSrc{logger: logger}})
+			hub.Uses("baz", Lazy(&DeclaringBadDataSrc{BadDataSrc{logger: logger}}))
+
+			err := Txn(hub, func(data any) errs.Err {
+				kvs, err := GetDataConnByType[KeyValueStore](data)
+				assert.True(t, err.IsOk())
+				assert.Equal(t, kvs.Get("k"), "k of 1")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		logger := list.New()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		hub.Alias("bar", "foo")
+		hub.Alias("baz", "qux")
+
+		hubImpl := hub.(*dataHubImpl)
+		assert.Equal(t, hubImpl.aliasMap, map[string]string{"bar": "foo", "baz": "qux"})
+
+		err := Run(hub, func(data any) errs.Err {
+			dc1, err := GetDataConn[*MyDataConn](data, "bar")
+			assert.True(t, err.IsOk())
+			dc2, err := GetDataConn[*MyDataConn](data, "foo")
+			assert.True(t, err.IsOk())
+			assert.Same(t, dc1, dc2)
+
+			_, err = GetDataConn[*MyDataConn](data, "baz")
+			switch rsn := err.Reason().(type) {
+			case NoDataSrcToCreateDataConn:
+				assert.Equal(t, rsn.Name, "qux")
+			default:
+				assert.Fail(t, err.Error())
+			}
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		hubImpl.fixed = true
+		hub.Alias("quux", "foo")
+		hubImpl.fixed = false
+		assert.Len(t, hubImpl.aliasMap, 2)
+
+		hub.Close()
+		assert.Empty(t, hubImpl.aliasMap)
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("begin with name conflict detection and duplicated names", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		DetectNameConflicts(true)
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+		hub.Uses("foo", NewMyDataSrc(3, Failure_None, logger))
+
+		err := hub.begin(false)
+		switch rsn := err.Reason().(type) {
+		case DuplicatedDataSrcNames:
+			assert.Equal(t, rsn.Names, []string{"foo"})
+		default:
+			assert.Fail(t, err.Error())
+		}
+		hub.end()
+
+		assert.Equal(t, logger.Len(), 0)
+	})
+
+	t.Run("begin with name conflict detection and shadowed names", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		DetectNameConflicts(true)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+			hub.Uses("bar", NewMyDataSrc(3, Failure_None, logger))
+			hub.Alias("bar", "foo")
+			hub.Alias("baz", "foo")
+
+			err := hub.begin(false)
+			switch rsn := err.Reason().(type) {
+			case ShadowedDataSrcNames:
+				assert.Equal(t, rsn.Names, []string{"foo", "bar"})
+			default:
+				assert.Fail(t, err.Error())
+			}
+			hub.end()
+		}()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("bar", NewMyDataSrc(3, Failure_None, logger))
+			hub.Alias("baz", "foo")
+
+			assert.True(t, hub.begin(false).IsOk())
+			hub.end()
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 3")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("begin without name conflict detection", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		hub.Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+		hub.Uses("foo", NewMyDataSrc(3, Failure_None, logger))
+
+		assert.True(t, hub.begin(false).IsOk())
+		hubImpl := hub.(*dataHubImpl)
+		assert.True(t, hubImpl.dataSrcMap["foo"].local)
+		hub.end()
+	})
+	t.Run("begin with RejectDuplicates policy", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		SetDuplicatePolicy(RejectDuplicates)
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		hub.Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+
+		hubImpl := hub.(*dataHubImpl)
+		assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 1)
+
+		err := Run(hub, func(data any) errs.Err {
+			logger.PushBack("execute logic")
+			return errs.Ok()
+		})
+		switch rsn := err.Reason().(type) {
+		case DuplicatedDataSrcNames:
+			assert.Equal(t, rsn.Names, []string{"foo"})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.Equal(t, logger.Len(), 0)
+	})
+
+	t.Run("Uses with ReplaceDuplicates policy", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		SetDuplicatePolicy(ReplaceDuplicates)
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+			hub.Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+
+			hubImpl := hub.(*dataHubImpl)
+			assert.Equal(t, countDs(hubImpl.localDataSrcManager.listUnready), 1)
+
+			err := Run(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+
+			hub.Uses("foo", NewMyDataSrc(3, Failure_None, logger))
+
+			err = Run(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 3")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+}
+
+func ResetGlobals() {
+	globalDataSrcsFixed.Store(false)
+	globalDataSrcsReady = false
+	globalDataSrcManager.close()
+	retiredDataSrcRefs = nil
+	globalLeakTracker = nil
+	globalLeakHandler = nil
+	nameConflictDetection = false
+	duplicatePolicy = KeepDuplicates
+	globalListeners = nil
+	globalLogger = nil
+	globalTracer = nil
+	globalMetricsSink = nil
+	globalInterceptors = nil
+}
+
+func TestGlobals(t *testing.T) {
+	t.Run("Uses and Setup, and ok", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 1)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			assert.True(t, globalDataSrcsFixed.Load())
+			assert.False(t, globalDataSrcManager.local)
+			assert.Len(t, globalDataSrcManager.listUnready, 0)
+			assert.Len(t, globalDataSrcManager.listReady, 1)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Uses and Setup, but fail", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 1)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsNotOk())
+
+			switch rsn := err.Reason().(type) {
+			case FailToSetupGlobalDataSrcs:
+				assert.Len(t, rsn.Errors, 1)
+				assert.Equal(t, rsn.Errors[0].Index, 0)
+				assert.Equal(t, rsn.Errors[0].Name, "foo")
+				assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
+			default:
+				assert.Fail(t, err.Error())
+			}
+
+			assert.True(t, globalDataSrcsFixed.Load())
+			assert.False(t, globalDataSrcManager.local)
+			assert.Len(t, globalDataSrcManager.listUnready, 0)
+			assert.Len(t, globalDataSrcManager.listReady, 0)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1 failed")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Uses and Setup, but already fixed before", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		assert.True(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+		assert.True(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Shutdown()
+
+		log := logger.Front()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Uses and SetupWithOrder, and ok", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 2)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		func() {
+			err := SetupWithOrder("bar", "foo")
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			assert.True(t, globalDataSrcsFixed.Load())
+			assert.False(t, globalDataSrcManager.local)
+			assert.Len(t, globalDataSrcManager.listUnready, 0)
+			assert.Len(t, globalDataSrcManager.listReady, 2)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Uses and SetupWithOrder, but fail", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_Setup, logger))
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 2)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		func() {
+			err := SetupWithOrder("bar", "foo")
+			defer Shutdown()
+			assert.True(t, err.IsNotOk())
+
+			switch rsn := err.Reason().(type) {
+			case FailToSetupGlobalDataSrcs:
+				assert.Len(t, rsn.Errors, 1)
+				assert.Equal(t, rsn.Errors[0].Index, 0)
+				assert.Equal(t, rsn.Errors[0].Name, "bar")
+				assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
+			default:
+				assert.Fail(t, err.Error())
+			}
+
+			assert.True(t, globalDataSrcsFixed.Load())
+			assert.False(t, globalDataSrcManager.local)
+			assert.Len(t, globalDataSrcManager.listUnready, 0)
+			assert.Len(t, globalDataSrcManager.listReady, 0)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2 failed")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("Uses and SetupWithOrder, but already fixed before", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.False(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		err := SetupWithOrder("bar", "foo")
+		assert.True(t, err.IsOk())
+
+		assert.True(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+		assert.True(t, globalDataSrcsFixed.Load())
+		assert.False(t, globalDataSrcManager.local)
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
 		assert.Len(t, globalDataSrcManager.listReady, 0)
 
 		Shutdown()
@@ -1611,4 +2157,257 @@ func TestGlobals(t *testing.T) {
 		log := logger.Front()
 		assert.Nil(t, log)
 	})
+
+	t.Run("Uses and Setup with name conflict detection, but fail", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		DetectNameConflicts(true)
+		assert.True(t, nameConflictDetection)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+		Uses("foo", NewMyDataSrc(3, Failure_None, logger))
+
+		err := Setup()
+		defer Shutdown()
+		switch rsn := err.Reason().(type) {
+		case DuplicatedDataSrcNames:
+			assert.Equal(t, rsn.Names, []string{"foo"})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.True(t, globalDataSrcsFixed.Load())
+		assert.Len(t, globalDataSrcManager.listUnready, 0)
+		assert.Len(t, globalDataSrcManager.listReady, 0)
+
+		DetectNameConflicts(false)
+		assert.True(t, nameConflictDetection)
+
+		assert.Equal(t, logger.Len(), 0)
+	})
+
+	t.Run("Uses and SetupWithOrder with name conflict detection, but fail", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		DetectNameConflicts(true)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+
+		err := SetupWithOrder("foo")
+		defer Shutdown()
+		switch rsn := err.Reason().(type) {
+		case DuplicatedDataSrcNames:
+			assert.Equal(t, rsn.Names, []string{"foo"})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.Equal(t, logger.Len(), 0)
+	})
+
+	t.Run("Uses and Setup with RejectDuplicates policy, but fail", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		assert.Equal(t, duplicatePolicy, KeepDuplicates)
+		SetDuplicatePolicy(RejectDuplicates)
+		assert.Equal(t, duplicatePolicy, RejectDuplicates)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("foo", NewMyDataSrc(2, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(3, Failure_None, logger))
+
+		assert.Len(t, globalDataSrcManager.listUnready, 2)
+
+		err := Setup()
+		defer Shutdown()
+		switch rsn := err.Reason().(type) {
+		case DuplicatedDataSrcNames:
+			assert.Equal(t, rsn.Names, []string{"foo"})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		SetDuplicatePolicy(ReplaceDuplicates)
+		assert.Equal(t, duplicatePolicy, RejectDuplicates)
+
+		assert.Equal(t, logger.Len(), 0)
+	})
+
+	t.Run("Uses and Setup with ReplaceDuplicates policy", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		SetDuplicatePolicy(ReplaceDuplicates)
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+		Uses("foo", NewMyDataSrc(3, Failure_None, logger))
+
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+			assert.Len(t, globalDataSrcManager.listReady, 2)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+}
+
+type TypedData interface {
+	Touch(name string) errs.Err
+}
+
+type TypedDataAcc struct {
+	DataAcc
+}
+
+func (da *TypedDataAcc) Touch(name string) errs.Err {
+	_, err := GetDataConn[*MyDataConn](da, name)
+	return err
+}
+
+type TypedDataHub struct {
+	DataHub
+	*TypedDataAcc
+}
+
+func NewTypedDataHub() TypedDataHub {
+	hub := NewDataHub()
+	return TypedDataHub{DataHub: hub, TypedDataAcc: &TypedDataAcc{DataAcc: hub}}
+}
+
+func typedLogic[D TypedData](data D) errs.Err {
+	return data.Touch("foo")
+}
+
+func TestRunOfAndTxnOf(t *testing.T) {
+	t.Run("RunOf", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewTypedDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+			err := RunOf(hub, typedLogic)
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("RunOf with interceptors but fail to setup", func(t *testing.T) {
+		logger := list.New()
+
+		hub := NewTypedDataHub()
+		defer hub.Close()
+
+		hub.Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+		err := RunOf(hub, typedLogic, newLoggingInterceptor("a", logger))
+		assert.True(t, err.IsNotOk())
+		switch err.Reason().(type) {
+		case FailToSetupLocalDataSrcs:
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("TxnOf", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewTypedDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+			err := TxnOf(hub, typedLogic, newLoggingInterceptor("a", logger))
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "a before")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "a after")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("TxnOf and rollback", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewTypedDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(1, Failure_Commit, logger))
+
+			err := TxnOf(hub, typedLogic)
+			assert.True(t, err.IsNotOk())
+			switch err.Reason().(type) {
+			case FailToCommitDataConn:
+			default:
+				assert.Fail(t, err.Error())
+			}
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1 failed")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Rollback 1")
+	})
 }
@@ -0,0 +1,215 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// DataConnsLeaked represents an error reason indicating that DataConns created from global
+	// data sources were not closed within the timeout passed to ShutdownWithTimeout, and so the
+	// data sources were closed while those DataConns were still open.
+	DataConnsLeaked struct {
+		Timeout time.Duration
+		Leaks   []DataConnLeak
+	}
+)
+
+// DataConnLeak is an entry of the leak report of DataConnsLeaked, which represents the number of
+// DataConns which were still open when the data source they were created from was closed.
+type DataConnLeak struct {
+	// Name is the name with which the data source is registered.
+	Name string
+	// DataSrcType is the type name of the data source.
+	DataSrcType string
+	// Count is the number of the DataConns which were still open.
+	Count int
+}
+
+// retiredDataSrcRefs holds the references of the global data sources which have been replaced by
+// Replace, so that ShutdownWithTimeout can wait for them to be closed.
+var retiredDataSrcRefs []*dataSrcRef
+
+// ShutdownWithTimeout works like Shutdown, but waits until all DataConns created from the global
+// data sources, including those replaced by Replace, are closed and the data sources are closed.
+//
+// If some DataConns are not closed within the timeout, this function closes their data sources
+// anyway and returns an error with the reason DataConnsLeaked, which reports the numbers of the
+// DataConns left open for each data source.
+// Leaks detected by DetectLeaks are reported after waiting.
+func ShutdownWithTimeout(timeout time.Duration) errs.Err {
+	refs := shutdown()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+wait:
+	for _, ref := range refs {
+		select {
+		case <-ref.closed:
+		case <-deadline.C:
+			break wait
+		}
+	}
+
+	var leaks []DataConnLeak
+	for _, ref := range refs {
+		if n := ref.forceClose(); n > 0 {
+			leaks = append(leaks, DataConnLeak{
+				Name: ref.cont.name, DataSrcType: dataSrcTypeNameOf(ref.cont.ds), Count: n})
+		}
+	}
+	reportLeaks()
+
+	if len(leaks) > 0 {
+		return errs.New(DataConnsLeaked{Timeout: timeout, Leaks: leaks})
+	}
+	return errs.Ok()
+}
+
+func shutdown() []*dataSrcRef {
+	globalDataSrcMutex.Lock()
+	defer globalDataSrcMutex.Unlock()
+
+	refs := make([]*dataSrcRef, 0, len(globalDataSrcManager.listReady)+len(retiredDataSrcRefs))
+	for i := len(globalDataSrcManager.listReady) - 1; i >= 0; i-- {
+		if cont := &globalDataSrcManager.listReady[i]; cont.ds != nil && cont.ref != nil {
+			refs = append(refs, cont.ref)
+		}
+	}
+	refs = append(refs, retiredDataSrcRefs...)
+	retiredDataSrcRefs = nil
+
+	globalDataSrcsReady = false
+	globalDataSrcManager.close()
+	return refs
+}
+
+// dataSrcRef counts the open DataConns created from a global data source, so that the data source
+// is closed by Shutdown or Replace only after all of them are closed.
+type dataSrcRef struct {
+	mutex   sync.Mutex
+	count   int
+	retired bool
+	done    bool
+	closed  chan struct{}
+	mgr     *dataSrcManager
+	cont    dataSrcContainer
+}
+
+func newDataSrcRef() *dataSrcRef {
+	return &dataSrcRef{closed: make(chan struct{})}
+}
+
+func (ref *dataSrcRef) acquire() bool {
+	if ref == nil {
+		return true
+	}
+
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	if ref.done {
+		return false
+	}
+	ref.count++
+	return true
+}
+
+func (ref *dataSrcRef) release() {
+	if ref == nil {
+		return
+	}
+
+	ref.mutex.Lock()
+	ref.count--
+	closeNow := ref.retired && ref.count == 0 && !ref.done
+	if closeNow {
+		ref.done = true
+	}
+	ref.mutex.Unlock()
+
+	if closeNow {
+		ref.close()
+	}
+}
+
+// retire marks the data source in the container as no longer used by new DataHubs, and closes it
+// now if no DataConn created from it is open, or otherwise when the last of them is closed.
+func (ref *dataSrcRef) retire(mgr *dataSrcManager, cont dataSrcContainer) {
+	if ref.markRetired(mgr, cont) {
+		ref.close()
+	}
+}
+
+// markRetired marks the data source in the container as no longer used by new DataHubs without
+// closing it, and returns true if no DataConn created from it is open and so the caller has to
+// close it by close.
+func (ref *dataSrcRef) markRetired(mgr *dataSrcManager, cont dataSrcContainer) bool {
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	ref.retired = true
+	ref.mgr = mgr
+	ref.cont = cont
+	closeNow := ref.count == 0 && !ref.done
+	if closeNow {
+		ref.done = true
+	}
+	return closeNow
+}
+
+// forceClose closes the retired data source if it has not been closed yet, and returns the number
+// of DataConns created from it which are still open.
+func (ref *dataSrcRef) forceClose() int {
+	ref.mutex.Lock()
+	if ref.done {
+		ref.mutex.Unlock()
+		return 0
+	}
+	ref.done = true
+	n := ref.count
+	ref.mutex.Unlock()
+
+	ref.close()
+	return n
+}
+
+func (ref *dataSrcRef) isClosed() bool {
+	select {
+	case <-ref.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ref *dataSrcRef) close() {
+	ref.mgr.closeDataSrc(&ref.cont)
+	close(ref.closed)
+}
+
+// acquireDataSrc counts a DataConn to be created from the global data source in the container.
+// If the data source has been replaced and closed, the container of the current global data source
+// with the same name is used instead, and false is returned if there is no such data source.
+func (hub *dataHubImpl) acquireDataSrc(dsCont dataSrcContainer) (dataSrcContainer, bool) {
+	for !dsCont.ref.acquire() {
+		globalDataSrcMutex.RLock()
+		index := globalDataSrcManager.indexOfReady(dsCont.name)
+		if index >= 0 {
+			dsCont = globalDataSrcManager.listReady[index]
+		}
+		globalDataSrcMutex.RUnlock()
+
+		if index < 0 {
+			return dsCont, false
+		}
+	}
+	return dsCont, true
+}
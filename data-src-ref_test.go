@@ -0,0 +1,238 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type HookedDataSrc struct {
+	*MyDataSrc
+	onClose func()
+}
+
+func (ds *HookedDataSrc) Close() {
+	ds.MyDataSrc.Close()
+	ds.onClose()
+}
+
+func TestDataSrcRef(t *testing.T) {
+	t.Run("Shutdown defers closing a data source in use", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		err = Run(NewDataHub(), func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			Shutdown()
+			logger.PushBack("shut down")
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "shut down")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("data sources examined by GetDataConnByType are not counted", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		bar := &CountingDataSrc{}
+
+		Uses("foo", &DeclaringDataSrc{NewMyDataSrc(1, Failure_None, logger)})
+		Uses("bar", bar)
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		err = Run(NewDataHub(), func(data any) errs.Err {
+			if _, err := GetDataConnByType[*MyDataConn](data); err.IsNotOk() {
+				return err
+			}
+			Shutdown()
+			assert.Equal(t, bar.closes.Load(), int32(1))
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("ShutdownWithTimeout waits for DataConns to be closed", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		acquired := make(chan struct{})
+		release := make(chan struct{})
+		finished := make(chan errs.Err)
+		go func() {
+			finished <- Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				close(acquired)
+				<-release
+				return err
+			})
+		}()
+		<-acquired
+
+		time.AfterFunc(50*time.Millisecond, func() { close(release) })
+		err = ShutdownWithTimeout(5 * time.Second)
+		assert.True(t, err.IsOk())
+		assert.True(t, (<-finished).IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("ShutdownWithTimeout waits for replaced data sources", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		acquired := make(chan struct{})
+		release := make(chan struct{})
+		finished := make(chan errs.Err)
+		go func() {
+			finished <- Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				close(acquired)
+				<-release
+				return err
+			})
+		}()
+		<-acquired
+
+		err = Replace("foo", &HookedDataSrc{
+			MyDataSrc: NewMyDataSrc(2, Failure_None, logger),
+			onClose:   func() { close(release) },
+		})
+		assert.True(t, err.IsOk())
+
+		err = ShutdownWithTimeout(5 * time.Second)
+		assert.True(t, err.IsOk())
+		assert.True(t, (<-finished).IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("ShutdownWithTimeout reports leaked DataConns", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		acquired := make(chan struct{})
+		release := make(chan struct{})
+		finished := make(chan errs.Err)
+		go func() {
+			finished <- Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				close(acquired)
+				<-release
+				return err
+			})
+		}()
+		<-acquired
+
+		err = ShutdownWithTimeout(50 * time.Millisecond)
+		switch r := err.Reason().(type) {
+		case DataConnsLeaked:
+			assert.Equal(t, r.Timeout, 50*time.Millisecond)
+			assert.Equal(t, r.Leaks, []DataConnLeak{
+				{Name: "foo", DataSrcType: "*sabi.MyDataSrc", Count: 1},
+			})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		close(release)
+		assert.True(t, (<-finished).IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+}
@@ -5,6 +5,9 @@
 package sabi
 
 import (
+	"reflect"
+	"time"
+
 	"github.com/sttk/errs"
 )
 
@@ -29,16 +32,108 @@ type DataSrc interface {
 	CreateDataConn() (DataConn, errs.Err)
 }
 
+// DataConnTypeDeclarer is an optional interface which a DataSrc implements to declare the type of
+// the DataConns created by its CreateDataConn, so that GetDataConnByType can find the data source
+// before any DataConn of it is created.
+//
+// A data source whose DataConns are DataConnWrappers should declare the type of the wrapped
+// DataConns, since GetDataConnByType looks through DataConnWrappers.
+type DataConnTypeDeclarer interface {
+	// DataConnType returns the type of the DataConns created by this data source, or nil if it
+	// is not known.
+	DataConnType() reflect.Type
+}
+
+// DataSrcWrapper is an interface implemented by a DataSrc which decorates another DataSrc, for
+// example to inject faults or to record calls in tests.
+//
+// sabi looks through such a data source for the optional interfaces of the decorated one, such
+// as HealthChecker and DataConnTypeDeclarer, unless the wrapper implements them itself, and
+// reports the type of the decorated one as the type of the data source.
+type DataSrcWrapper interface {
+	DataSrc
+
+	// Unwrap returns the decorated DataSrc.
+	Unwrap() DataSrc
+}
+
+func unwrapDataSrc(ds any) (any, bool) {
+	switch w := ds.(type) {
+	case interface{ wrappedDataSrc() any }:
+		return w.wrappedDataSrc(), true
+	case DataSrcWrapper:
+		return w.Unwrap(), true
+	default:
+		return nil, false
+	}
+}
+
+func declaredDataConnTypeOf(ds any) (reflect.Type, bool) {
+	for {
+		if d, ok := ds.(DataConnTypeDeclarer); ok {
+			t := d.DataConnType()
+			return t, t != nil
+		}
+		w, ok := unwrapDataSrc(ds)
+		if !ok {
+			return nil, false
+		}
+		ds = w
+	}
+}
+
+func dataConnTypeMatches(t, target reflect.Type) bool {
+	if target.Kind() == reflect.Interface {
+		return t.Implements(target)
+	}
+	return t == target
+}
+
+// DuplicatePolicy represents how a data source registered with the same name as an already
+// registered one in the same scope is handled by Uses and DataHub.Uses.
+type DuplicatePolicy uint8
+
+// The following constants represent the policies for duplicated registrations.
+const (
+	// KeepDuplicates keeps all data sources registered with the same name, and the one registered
+	// last takes precedence. All of them are set up and closed. This is the default policy.
+	KeepDuplicates DuplicatePolicy = iota
+	// RejectDuplicates ignores a data source registered with the same name as an already
+	// registered one, and makes Setup, SetupWithOrder, Run and Txn fail with the reason
+	// DuplicatedDataSrcNames.
+	RejectDuplicates
+	// ReplaceDuplicates replaces an already registered data source with the newly registered one
+	// with the same name. The replaced data source is closed if it has already been set up.
+	ReplaceDuplicates
+)
+
+// String returns the string representation of the DuplicatePolicy.
+func (policy DuplicatePolicy) String() string {
+	var s string
+	switch policy {
+	case KeepDuplicates:
+		s = "KeepDuplicates"
+	case RejectDuplicates:
+		s = "RejectDuplicates"
+	case ReplaceDuplicates:
+		s = "ReplaceDuplicates"
+	}
+	return s
+}
+
 type dataSrcContainer struct {
 	local bool
 	name  string
 	ds    DataSrc
+	ref   *dataSrcRef
 }
 
 type dataSrcManager struct {
-	local       bool
-	listUnready []dataSrcContainer
-	listReady   []dataSrcContainer
+	local         bool
+	listUnready   []dataSrcContainer
+	listReady     []dataSrcContainer
+	rejectedNames []string
+	observer      *observer
 }
 
 func newDataSrcManager(local bool) dataSrcManager {
@@ -50,13 +145,40 @@ func newDataSrcManager(local bool) dataSrcManager {
 }
 
 func (mgr *dataSrcManager) add(name string, ds DataSrc) {
-	mgr.listUnready = append(mgr.listUnready, dataSrcContainer{local: mgr.local, name: name, ds: ds})
+	switch duplicatePolicy {
+	case RejectDuplicates:
+		if mgr.has(name) {
+			mgr.rejectedNames = append(mgr.rejectedNames, name)
+			return
+		}
+	case ReplaceDuplicates:
+		mgr.remove(name)
+	}
+	cont := dataSrcContainer{local: mgr.local, name: name, ds: ds}
+	if !mgr.local {
+		cont.ref = newDataSrcRef()
+	}
+	mgr.listUnready = append(mgr.listUnready, cont)
+}
+
+func (mgr *dataSrcManager) has(name string) bool {
+	for i := range mgr.listReady {
+		if mgr.listReady[i].name == name && mgr.listReady[i].ds != nil {
+			return true
+		}
+	}
+	for i := range mgr.listUnready {
+		if mgr.listUnready[i].name == name && mgr.listUnready[i].ds != nil {
+			return true
+		}
+	}
+	return false
 }
 
 func (mgr *dataSrcManager) remove(name string) {
 	for i := range mgr.listReady {
 		if mgr.listReady[i].name == name && mgr.listReady[i].ds != nil {
-			mgr.listReady[i].ds.Close()
+			mgr.closeDataSrc(&mgr.listReady[i])
 			mgr.listReady[i].ds = nil
 		}
 	}
@@ -70,7 +192,11 @@ func (mgr *dataSrcManager) remove(name string) {
 func (mgr *dataSrcManager) close() {
 	for i := len(mgr.listReady) - 1; i >= 0; i-- {
 		if mgr.listReady[i].ds != nil {
-			mgr.listReady[i].ds.Close()
+			if mgr.listReady[i].ref != nil {
+				mgr.listReady[i].ref.retire(mgr, mgr.listReady[i])
+			} else {
+				mgr.closeDataSrc(&mgr.listReady[i])
+			}
 			mgr.listReady[i].ds = nil
 		}
 	}
@@ -81,6 +207,7 @@ func (mgr *dataSrcManager) close() {
 	}
 	mgr.listReady = nil
 	mgr.listUnready = nil
+	mgr.rejectedNames = nil
 }
 
 func (mgr *dataSrcManager) setup() []ErrEntry {
@@ -88,23 +215,29 @@ func (mgr *dataSrcManager) setup() []ErrEntry {
 		return nil
 	}
 
+	var recs []phaseRecord
+
 	ag := AsyncGroup{}
 	ii := 0
 	nDone := 0
 	for i := range mgr.listUnready {
-		if mgr.listUnready[i].ds == nil {
+		if mgr.listUnready[i].ds == nil || isLazyDataSrc(mgr.listUnready[i].ds) {
 			continue
 		}
 		ag._name = mgr.listUnready[i].name
 		ag._index = ii
 		ii++
-		if err := mgr.listUnready[i].ds.Setup(&ag); err.IsNotOk() {
+		start := time.Now()
+		err := mgr.listUnready[i].ds.Setup(&ag)
+		recs = mgr.record(recs, i, ag._index, start)
+		if err.IsNotOk() {
 			ag.addErr(ag._index, ag._name, err)
 			nDone = i
 			break
 		}
 	}
 	errors := ag.join()
+	mgr.notifySetup(recs, errors)
 
 	if len(errors) == 0 {
 		for i := range mgr.listUnready {
@@ -117,7 +250,7 @@ func (mgr *dataSrcManager) setup() []ErrEntry {
 	} else {
 		for i := nDone - 1; i >= 0; i-- {
 			if mgr.listUnready[i].ds != nil {
-				mgr.listUnready[i].ds.Close()
+				mgr.closeDataSrc(&mgr.listUnready[i])
 			}
 		}
 		return errors
@@ -151,6 +284,8 @@ func (mgr *dataSrcManager) setupWithOrder(names []string) []ErrEntry {
 		}
 	}
 
+	var recs []phaseRecord
+
 	ag := AsyncGroup{}
 	ii := 0
 	nDone := 0
@@ -159,19 +294,23 @@ func (mgr *dataSrcManager) setupWithOrder(names []string) []ErrEntry {
 			continue
 		}
 		listIndex := listIndexPlusOffset - offsetAvoidingUnset
-		if mgr.listUnready[listIndex].ds == nil {
+		if mgr.listUnready[listIndex].ds == nil || isLazyDataSrc(mgr.listUnready[listIndex].ds) {
 			continue
 		}
 		ag._name = mgr.listUnready[listIndex].name
 		ag._index = ii
 		ii++
-		if err := mgr.listUnready[listIndex].ds.Setup(&ag); err.IsNotOk() {
+		start := time.Now()
+		err := mgr.listUnready[listIndex].ds.Setup(&ag)
+		recs = mgr.record(recs, listIndex, ag._index, start)
+		if err.IsNotOk() {
 			ag.addErr(ag._index, ag._name, err)
 			nDone = orderIndex
 			break
 		}
 	}
 	errors := ag.join()
+	mgr.notifySetup(recs, errors)
 
 	if len(errors) == 0 {
 		for _, listIndexPlusOffset := range orderedIndexes {
@@ -192,7 +331,7 @@ func (mgr *dataSrcManager) setupWithOrder(names []string) []ErrEntry {
 			if listIndexPlusOffset > 0 { // Ignore unset
 				listIndex := listIndexPlusOffset - offsetAvoidingUnset
 				if mgr.listUnready[listIndex].ds != nil {
-					mgr.listUnready[listIndex].ds.Close()
+					mgr.closeDataSrc(&mgr.listUnready[listIndex])
 				}
 			}
 		}
@@ -200,6 +339,95 @@ func (mgr *dataSrcManager) setupWithOrder(names []string) []ErrEntry {
 	}
 }
 
+func (mgr *dataSrcManager) record(
+	recs []phaseRecord, listIndex, index int, start time.Time,
+) []phaseRecord {
+	if !mgr.observer.isActive() {
+		return recs
+	}
+	return append(recs, phaseRecord{
+		listIndex: listIndex, index: index, start: start, duration: time.Since(start)})
+}
+
+func (mgr *dataSrcManager) notifySetup(recs []phaseRecord, errors []ErrEntry) {
+	for _, rec := range recs {
+		err := errs.Ok()
+		for i := range errors {
+			if errors[i].Index == rec.index {
+				err = errors[i].Err
+				break
+			}
+		}
+		cont := &mgr.listUnready[rec.listIndex]
+		mgr.observer.notify(Event{
+			Phase:        PhaseSetupDataSrc,
+			DataConnName: cont.name,
+			DataSrcType:  dataSrcTypeNameOf(cont.ds),
+			Start:        rec.start,
+			Duration:     rec.duration,
+			Err:          err,
+		})
+	}
+}
+
+func (mgr *dataSrcManager) closeDataSrc(cont *dataSrcContainer) {
+	if !mgr.observer.isActive() {
+		cont.ds.Close()
+		return
+	}
+
+	start := time.Now()
+	cont.ds.Close()
+	mgr.observer.notify(Event{
+		Phase:        PhaseCloseDataSrc,
+		DataConnName: cont.name,
+		DataSrcType:  dataSrcTypeNameOf(cont.ds),
+		Start:        start,
+		Duration:     time.Since(start),
+		Err:          errs.Ok(),
+	})
+}
+
+func (mgr *dataSrcManager) duplicatedNames() []string {
+	counts := make(map[string]int)
+	names := make([]string, 0)
+	count := func(list []dataSrcContainer) {
+		for i := range list {
+			if list[i].ds == nil {
+				continue
+			}
+			name := list[i].name
+			counts[name]++
+			if counts[name] == 2 {
+				names = append(names, name)
+			}
+		}
+	}
+	count(mgr.listReady)
+	count(mgr.listUnready)
+	for _, name := range mgr.rejectedNames {
+		if counts[name] < 2 {
+			counts[name] = 2
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (mgr *dataSrcManager) shadowedNames(contMap map[string]dataSrcContainer) []string {
+	names := make([]string, 0)
+	for i := range mgr.listUnready {
+		if mgr.listUnready[i].ds == nil {
+			continue
+		}
+		name := mgr.listUnready[i].name
+		if cont, ok := contMap[name]; ok && cont.local != mgr.local {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (mgr *dataSrcManager) copyDsReadyToMap(contMap map[string]dataSrcContainer) {
 	for i := range mgr.listReady {
 		contPtr := &mgr.listReady[i]
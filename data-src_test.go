@@ -683,4 +683,128 @@ func TestDataSrc(t *testing.T) {
 		assert.False(t, contMap["baz"].local)
 		assert.Equal(t, contMap["baz"].name, "baz")
 	})
+
+	t.Run("duplicatedNames", func(t *testing.T) {
+		logger := list.New()
+
+		manager := newDataSrcManager(true)
+		defer manager.close()
+		assert.Empty(t, manager.duplicatedNames())
+
+		ds1 := NewSyncDataSrc(1, logger, Fail2_Not)
+		ds2 := NewSyncDataSrc(2, logger, Fail2_Not)
+		ds3 := NewSyncDataSrc(3, logger, Fail2_Not)
+		ds4 := NewSyncDataSrc(4, logger, Fail2_Not)
+		manager.add("foo", &ds1)
+		manager.add("bar", &ds2)
+		assert.Empty(t, manager.duplicatedNames())
+		assert.Len(t, manager.setup(), 0)
+
+		manager.add("bar", &ds3)
+		manager.add("foo", &ds4)
+		manager.add("foo", &ds4)
+		assert.Equal(t, manager.duplicatedNames(), []string{"bar", "foo"})
+
+		manager.remove("bar")
+		assert.Equal(t, manager.duplicatedNames(), []string{"foo"})
+	})
+
+	t.Run("shadowedNames", func(t *testing.T) {
+		logger := list.New()
+
+		contMap := make(map[string]dataSrcContainer)
+
+		global := newDataSrcManager(false)
+		ds1 := NewSyncDataSrc(1, logger, Fail2_Not)
+		ds2 := NewSyncDataSrc(2, logger, Fail2_Not)
+		global.add("foo", &ds1)
+		global.add("bar", &ds2)
+		assert.Len(t, global.setup(), 0)
+		global.copyDsReadyToMap(contMap)
+
+		local := newDataSrcManager(true)
+		assert.Empty(t, local.shadowedNames(contMap))
+
+		ds3 := NewSyncDataSrc(3, logger, Fail2_Not)
+		ds4 := NewSyncDataSrc(4, logger, Fail2_Not)
+		local.add("baz", &ds3)
+		local.add("bar", &ds4)
+		assert.Equal(t, local.shadowedNames(contMap), []string{"bar"})
+
+		assert.Len(t, local.setup(), 0)
+		local.copyDsReadyToMap(contMap)
+		assert.Empty(t, local.shadowedNames(contMap))
+	})
+
+	t.Run("add with RejectDuplicates policy", func(t *testing.T) {
+		defer func() { duplicatePolicy = KeepDuplicates }()
+		duplicatePolicy = RejectDuplicates
+
+		logger := list.New()
+
+		manager := newDataSrcManager(true)
+		defer manager.close()
+
+		ds1 := NewSyncDataSrc(1, logger, Fail2_Not)
+		ds2 := NewSyncDataSrc(2, logger, Fail2_Not)
+		manager.add("foo", &ds1)
+		manager.add("foo", &ds2)
+		manager.add("foo", &ds2)
+
+		assert.Len(t, manager.listUnready, 1)
+		assert.Equal(t, manager.rejectedNames, []string{"foo", "foo"})
+		assert.Equal(t, manager.duplicatedNames(), []string{"foo"})
+
+		manager.close()
+		assert.Nil(t, manager.rejectedNames)
+	})
+
+	t.Run("add with ReplaceDuplicates policy", func(t *testing.T) {
+		defer func() { duplicatePolicy = KeepDuplicates }()
+		duplicatePolicy = ReplaceDuplicates
+
+		logger := list.New()
+
+		func() {
+			manager := newDataSrcManager(true)
+			defer manager.close()
+
+			ds1 := NewSyncDataSrc(1, logger, Fail2_Not)
+			ds2 := NewSyncDataSrc(2, logger, Fail2_Not)
+			ds3 := NewSyncDataSrc(3, logger, Fail2_Not)
+			manager.add("foo", &ds1)
+			assert.Len(t, manager.setup(), 0)
+			manager.add("foo", &ds2)
+			manager.add("foo", &ds3)
+
+			assert.Equal(t, countDs(manager.listReady), 0)
+			assert.Equal(t, countDs(manager.listUnready), 1)
+			assert.Empty(t, manager.duplicatedNames())
+			assert.Len(t, manager.setup(), 0)
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "SyncDataSrc.New 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.New 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.New 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.Setup 3")
+		log = log.Next()
+		assert.Equal(t, log.Value, "SyncDataSrc.Close 3")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("DuplicatePolicy String", func(t *testing.T) {
+		assert.Equal(t, KeepDuplicates.String(), "KeepDuplicates")
+		assert.Equal(t, RejectDuplicates.String(), "RejectDuplicates")
+		assert.Equal(t, ReplaceDuplicates.String(), "ReplaceDuplicates")
+		assert.Equal(t, DuplicatePolicy(99).String(), "")
+	})
 }
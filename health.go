@@ -0,0 +1,187 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// HealthCheckTimedOut represents an error reason indicating that the health check of a data
+	// source did not finish within the timeout passed to Health.
+	HealthCheckTimedOut struct {
+		Name    string
+		Timeout time.Duration
+	}
+)
+
+// HealthChecker is an optional interface which a DataSrc implements to report whether it is
+// still healthy after Setup, for example by pinging its database.
+type HealthChecker interface {
+	// CheckHealth checks the health of the data source and returns an error if it is unhealthy.
+	// It should return early when the context is done.
+	CheckHealth(ctx context.Context) errs.Err
+}
+
+// HealthState represents the result of the health check of a data source.
+type HealthState uint8
+
+// The following constants represent the results of health checks.
+const (
+	// NotChecked indicates that the data source does not implement HealthChecker, or that it is
+	// registered with Lazy and has not been set up yet.
+	NotChecked HealthState = iota
+	// Healthy indicates that the health check succeeded.
+	Healthy
+	// Unhealthy indicates that the health check returned an error.
+	Unhealthy
+	// TimedOut indicates that the health check did not finish within the timeout.
+	TimedOut
+)
+
+// String returns the string representation of the HealthState.
+func (state HealthState) String() string {
+	var s string
+	switch state {
+	case NotChecked:
+		s = "NotChecked"
+	case Healthy:
+		s = "Healthy"
+	case Unhealthy:
+		s = "Unhealthy"
+	case TimedOut:
+		s = "TimedOut"
+	}
+	return s
+}
+
+// HealthStatus is the result of the health check of a global data source.
+type HealthStatus struct {
+	// Name is the name with which the data source is registered.
+	Name string
+	// State is the result of the health check.
+	State HealthState
+	// Err is the error returned by the health check, or an error with the reason
+	// HealthCheckTimedOut if the check timed out.
+	Err errs.Err
+	// Duration is the time taken by the health check.
+	Duration time.Duration
+}
+
+// HealthReport is the result of Health.
+type HealthReport struct {
+	// Ready is true if the global data sources have been set up by Setup or SetupWithOrder
+	// successfully and have not been shut down yet.
+	Ready bool
+	// Statuses are the results of the health checks of the global data sources in the order of
+	// their setup.
+	Statuses []HealthStatus
+}
+
+// IsHealthy reports whether no data source is Unhealthy or TimedOut.
+func (report HealthReport) IsHealthy() bool {
+	for i := range report.Statuses {
+		switch report.Statuses[i].State {
+		case Unhealthy, TimedOut:
+			return false
+		}
+	}
+	return true
+}
+
+// IsReady reports whether the global data sources are ready and healthy.
+func (report HealthReport) IsReady() bool {
+	return report.Ready && report.IsHealthy()
+}
+
+// Health checks the health of all global data sources which implement HealthChecker in parallel,
+// and returns their statuses.
+//
+// Each check is given a context derived from ctx which is canceled after the timeout, and a check
+// which does not finish within the timeout is reported as TimedOut without waiting for it.
+// Like Uses, this function must not be called concurrently with Setup or SetupWithOrder.
+func Health(ctx context.Context, timeout time.Duration) HealthReport {
+	globalDataSrcMutex.RLock()
+	report := HealthReport{Ready: globalDataSrcsReady}
+	conts := append([]dataSrcContainer(nil), globalDataSrcManager.listReady...)
+	globalDataSrcMutex.RUnlock()
+
+	report.Statuses = make([]HealthStatus, 0, len(conts))
+	checkers := make([]HealthChecker, 0, len(conts))
+	for i := range conts {
+		if conts[i].ds != nil {
+			report.Statuses = append(report.Statuses, HealthStatus{
+				Name: conts[i].name, State: NotChecked, Err: errs.Ok()})
+			hc, _ := healthCheckerOf(conts[i].ds)
+			checkers = append(checkers, hc)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		index    int
+		err      errs.Err
+		duration time.Duration
+	}
+	ch := make(chan result, len(checkers))
+	n := 0
+	start := time.Now()
+	for i, hc := range checkers {
+		if hc == nil {
+			continue
+		}
+		n++
+		go func(i int, hc HealthChecker) {
+			err := hc.CheckHealth(ctx)
+			ch <- result{index: i, err: err, duration: time.Since(start)}
+		}(i, hc)
+	}
+
+	for ; n > 0; n-- {
+		select {
+		case r := <-ch:
+			status := &report.Statuses[r.index]
+			status.Duration = r.duration
+			status.Err = r.err
+			if r.err.IsOk() {
+				status.State = Healthy
+			} else {
+				status.State = Unhealthy
+			}
+		case <-ctx.Done():
+			for i, hc := range checkers {
+				status := &report.Statuses[i]
+				if hc != nil && status.State == NotChecked {
+					status.State = TimedOut
+					status.Duration = time.Since(start)
+					status.Err = errs.New(HealthCheckTimedOut{Name: status.Name, Timeout: timeout})
+				}
+			}
+			return report
+		}
+	}
+	return report
+}
+
+func healthCheckerOf(ds any) (HealthChecker, bool) {
+	for {
+		if lazy, ok := ds.(*lazyDataSrc); ok && !lazy.isSetUp() {
+			return nil, false
+		}
+		if hc, ok := ds.(HealthChecker); ok {
+			return hc, true
+		}
+		w, ok := unwrapDataSrc(ds)
+		if !ok {
+			return nil, false
+		}
+		ds = w
+	}
+}
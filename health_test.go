@@ -0,0 +1,160 @@
+package sabi
+
+import (
+	"container/list"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type HealthDataSrc struct {
+	MyDataSrc
+	delay time.Duration
+	err   errs.Err
+}
+
+func NewHealthDataSrc(id uint8, delay time.Duration, err errs.Err) *HealthDataSrc {
+	return &HealthDataSrc{
+		MyDataSrc: MyDataSrc{id: id, logger: list.New()}, delay: delay, err: err}
+}
+
+func (ds *HealthDataSrc) CheckHealth(ctx context.Context) errs.Err {
+	select {
+	case <-time.After(ds.delay):
+		return ds.err
+	case <-ctx.Done():
+		time.Sleep(ds.delay)
+		return errs.New("canceled")
+	}
+}
+
+type WrappingDataSrc struct {
+	DataSrc
+}
+
+func (ds *WrappingDataSrc) Unwrap() DataSrc {
+	return ds.DataSrc
+}
+
+func TestHealthState(t *testing.T) {
+	assert.Equal(t, NotChecked.String(), "NotChecked")
+	assert.Equal(t, Healthy.String(), "Healthy")
+	assert.Equal(t, Unhealthy.String(), "Unhealthy")
+	assert.Equal(t, TimedOut.String(), "TimedOut")
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("before Setup", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		Uses("foo", NewHealthDataSrc(1, 0, errs.Ok()))
+
+		report := Health(context.Background(), time.Second)
+		assert.False(t, report.Ready)
+		assert.Len(t, report.Statuses, 0)
+		assert.True(t, report.IsHealthy())
+		assert.False(t, report.IsReady())
+	})
+
+	t.Run("healthy", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		Uses("foo", NewHealthDataSrc(1, 0, errs.Ok()))
+		Uses("bar", NewMyDataSrc(2, Failure_None, list.New()))
+		Uses("baz", NewHealthDataSrc(3, 10*time.Millisecond, errs.Ok()))
+
+		assert.True(t, Setup().IsOk())
+
+		report := Health(context.Background(), time.Second)
+		assert.True(t, report.Ready)
+		assert.True(t, report.IsHealthy())
+		assert.True(t, report.IsReady())
+		assert.Len(t, report.Statuses, 3)
+		assert.Equal(t, report.Statuses[0].Name, "foo")
+		assert.Equal(t, report.Statuses[0].State, Healthy)
+		assert.True(t, report.Statuses[0].Err.IsOk())
+		assert.Equal(t, report.Statuses[1].Name, "bar")
+		assert.Equal(t, report.Statuses[1].State, NotChecked)
+		assert.Equal(t, report.Statuses[2].Name, "baz")
+		assert.Equal(t, report.Statuses[2].State, Healthy)
+		assert.GreaterOrEqual(t, report.Statuses[2].Duration, 10*time.Millisecond)
+
+		Shutdown()
+
+		report = Health(context.Background(), time.Second)
+		assert.False(t, report.Ready)
+		assert.Len(t, report.Statuses, 0)
+	})
+
+	t.Run("unhealthy and timed out", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		Uses("foo", NewHealthDataSrc(1, 0, errs.New("down")))
+		Uses("bar", NewHealthDataSrc(2, time.Second, errs.Ok()))
+		Uses("baz", NewHealthDataSrc(3, 0, errs.Ok()))
+
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		start := time.Now()
+		report := Health(context.Background(), 50*time.Millisecond)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+		assert.True(t, report.Ready)
+		assert.False(t, report.IsHealthy())
+		assert.False(t, report.IsReady())
+
+		assert.Equal(t, report.Statuses[0].State, Unhealthy)
+		assert.Equal(t, report.Statuses[0].Err.Reason(), "down")
+		assert.Equal(t, report.Statuses[1].State, TimedOut)
+		assert.Equal(t, report.Statuses[1].Err.Reason(),
+			HealthCheckTimedOut{Name: "bar", Timeout: 50 * time.Millisecond})
+		assert.Equal(t, report.Statuses[2].State, Healthy)
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		Uses("foo", Lazy(NewHealthDataSrc(1, 0, errs.New("down"))))
+
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		report := Health(context.Background(), time.Second)
+		assert.Equal(t, report.Statuses[0].State, NotChecked)
+
+		hub := NewDataHub()
+		defer hub.Close()
+		err := Run(hub, func(data any) errs.Err {
+			_, err := GetDataConn[*MyDataConn](data, "foo")
+			return err
+		})
+		assert.True(t, err.IsOk())
+
+		report = Health(context.Background(), time.Second)
+		assert.Equal(t, report.Statuses[0].State, Unhealthy)
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		Uses("foo", &WrappingDataSrc{NewHealthDataSrc(1, 0, errs.New("down"))})
+		Uses("bar", &WrappingDataSrc{NewMyDataSrc(2, Failure_None, list.New())})
+
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		report := Health(context.Background(), time.Second)
+		assert.Equal(t, report.Statuses[0].State, Unhealthy)
+		assert.Equal(t, report.Statuses[0].Err.Reason(), "down")
+		assert.Equal(t, report.Statuses[1].State, NotChecked)
+	})
+}
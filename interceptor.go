@@ -0,0 +1,52 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"github.com/sttk/errs"
+)
+
+// Interceptor is a function which wraps the invocation of a logic function by Run or Txn.
+//
+// An interceptor receives the DataHub executing the logic and a function next which invokes the
+// rest of the interceptor chain and finally the logic. It can do something before and after
+// calling next, skip calling next to reject the execution, and return an error other than the
+// one returned by next to translate it.
+// In Txn, the error returned by the outermost interceptor determines whether the transaction is
+// committed or rolled back.
+type Interceptor func(hub DataHub, next func() errs.Err) errs.Err
+
+var globalInterceptors []Interceptor
+
+// AddInterceptor registers a global Interceptor which wraps all logic functions executed by Run
+// and Txn. Like Uses, this registration must occur before Setup is called.
+//
+// Global interceptors wrap interceptors passed to Run and Txn, and among each of them, an
+// interceptor registered earlier wraps the ones registered later.
+func AddInterceptor(ic Interceptor) {
+	if !globalDataSrcsFixed.Load() {
+		globalInterceptors = append(globalInterceptors, ic)
+	}
+}
+
+func invokeLogic(hub DataHub, logic func() errs.Err, interceptors []Interceptor) errs.Err {
+	if len(globalInterceptors) == 0 && len(interceptors) == 0 {
+		return logic()
+	}
+
+	next := wrapLogic(hub, logic, interceptors)
+	next = wrapLogic(hub, next, globalInterceptors)
+	return next()
+}
+
+func wrapLogic(hub DataHub, next func() errs.Err, interceptors []Interceptor) func() errs.Err {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic, inner := interceptors[i], next
+		next = func() errs.Err {
+			return ic(hub, inner)
+		}
+	}
+	return next
+}
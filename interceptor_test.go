@@ -0,0 +1,179 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func newLoggingInterceptor(name string, logger *list.List) Interceptor {
+	return func(hub DataHub, next func() errs.Err) errs.Err {
+		logger.PushBack(name + " before")
+		err := next()
+		if err.IsOk() {
+			logger.PushBack(name + " after")
+		} else {
+			logger.PushBack(name + " after " + err.Reason().(string))
+		}
+		return err
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Run("AddInterceptor", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		AddInterceptor(newLoggingInterceptor("g", logger))
+		assert.Len(t, globalInterceptors, 1)
+
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		AddInterceptor(newLoggingInterceptor("g", logger))
+		assert.Len(t, globalInterceptors, 1)
+	})
+
+	t.Run("run with global and per call interceptors", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		AddInterceptor(newLoggingInterceptor("g1", logger))
+		AddInterceptor(newLoggingInterceptor("g2", logger))
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		var hubInInterceptor DataHub
+		err := Run(hub, func(data any) errs.Err {
+			logger.PushBack("logic")
+			return errs.Ok()
+		},
+			newLoggingInterceptor("c1", logger),
+			func(hub DataHub, next func() errs.Err) errs.Err {
+				hubInInterceptor = hub
+				return next()
+			},
+		)
+		assert.True(t, err.IsOk())
+		assert.Equal(t, hubInInterceptor, hub)
+
+		log := logger.Front()
+		for _, s := range []string{
+			"g1 before", "g2 before", "c1 before", "logic", "c1 after", "g2 after", "g1 after",
+		} {
+			assert.Equal(t, log.Value, s)
+			log = log.Next()
+		}
+		assert.Nil(t, log)
+	})
+
+	t.Run("reject execution", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			logger.PushBack("logic")
+			return errs.Ok()
+		}, func(hub DataHub, next func() errs.Err) errs.Err {
+			return errs.New("unauthorized")
+		})
+		assert.Equal(t, err.Reason(), "unauthorized")
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("translate error and roll back", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		translate := func(hub DataHub, next func() errs.Err) errs.Err {
+			err := next()
+			if err.IsNotOk() {
+				return errs.New("translated", err)
+			}
+			return err
+		}
+
+		err := Txn(hub, func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			return errs.New("logic error")
+		}, translate, newLoggingInterceptor("c", logger))
+		assert.Equal(t, err.Reason(), "translated")
+		assert.Equal(t, err.Cause().(errs.Err).Reason(), "logic error")
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "c before")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "c after logic error")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Rollback 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#OnTxnFailure 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("translate error and commit", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			return errs.New("ignorable")
+		}, func(hub DataHub, next func() errs.Err) errs.Err {
+			if err := next(); err.Reason() != "ignorable" {
+				return err
+			}
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"reflect"
+
+	"github.com/sttk/errs"
+)
+
+// IoDataSrc is an in-memory DataSrc which routes the input and output of a controller directly
+// to the data access layer, bypassing the logic layer.
+//
+// An IoDataSrc is created with an inbound payload of type I and registered to a DataHub by
+// DataHub.Uses. DataAcc implementations read the input and write an output of type O through
+// IoDataConn. The output is published only in the post-commit phase of Txn, that is, after all
+// DataConns of the transaction are committed successfully, or when the logic executed by Run
+// succeeds, so the controller can receive it by Output or the publish function without having to
+// know whether the logic is transactional.
+// This works the same in CLI and HTTP contexts, which keeps controllers thin.
+type IoDataSrc[I, O any] struct {
+	input     I
+	output    O
+	published bool
+	publish   func(output O) errs.Err
+}
+
+// NewIoDataSrc creates a new IoDataSrc with the input.
+// If publish is not nil, it is called with the output when the output is published, and an
+// error returned by it makes the post-commit phase of Txn or the completion of Run fail.
+func NewIoDataSrc[I, O any](input I, publish func(output O) errs.Err) *IoDataSrc[I, O] {
+	return &IoDataSrc[I, O]{input: input, publish: publish}
+}
+
+// Setup does nothing.
+func (ds *IoDataSrc[I, O]) Setup(ag *AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+
+// Close does nothing.
+func (ds *IoDataSrc[I, O]) Close() {
+}
+
+// CreateDataConn creates a new IoDataConn for the input of this data source.
+func (ds *IoDataSrc[I, O]) CreateDataConn() (DataConn, errs.Err) {
+	return &IoDataConn[I, O]{ds: ds}, errs.Ok()
+}
+
+// DataConnType returns the type of the DataConns created by this data source, *IoDataConn[I, O].
+func (ds *IoDataSrc[I, O]) DataConnType() reflect.Type {
+	return reflect.TypeFor[*IoDataConn[I, O]]()
+}
+
+// Output returns the published output and true, or the zero value and false if no output has
+// been published.
+func (ds *IoDataSrc[I, O]) Output() (O, bool) {
+	return ds.output, ds.published
+}
+
+// IoDataConn is a DataConn to read the input and to write the output of an IoDataSrc.
+type IoDataConn[I, O any] struct {
+	ds        *IoDataSrc[I, O]
+	output    O
+	hasOutput bool
+	committed bool
+}
+
+// Input returns the input of the data source.
+func (conn *IoDataConn[I, O]) Input() I {
+	return conn.ds.input
+}
+
+// SetOutput sets the output, which replaces the output set before.
+func (conn *IoDataConn[I, O]) SetOutput(output O) {
+	conn.output = output
+	conn.hasOutput = true
+}
+
+// Output returns the output set by SetOutput and true, or the zero value and false if no output
+// has been set or it has been discarded by Rollback.
+func (conn *IoDataConn[I, O]) Output() (O, bool) {
+	return conn.output, conn.hasOutput
+}
+
+// PreCommit does nothing.
+func (conn *IoDataConn[I, O]) PreCommit(ag *AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+
+// Commit does nothing but marks this connection as committed.
+func (conn *IoDataConn[I, O]) Commit(ag *AsyncGroup) errs.Err {
+	conn.committed = true
+	return errs.Ok()
+}
+
+// PostCommit publishes the output if it has been set.
+func (conn *IoDataConn[I, O]) PostCommit(ag *AsyncGroup) errs.Err {
+	return conn.publish()
+}
+
+func (conn *IoDataConn[I, O]) completeRun(ag *AsyncGroup) errs.Err {
+	return conn.publish()
+}
+
+func (conn *IoDataConn[I, O]) publish() errs.Err {
+	if !conn.hasOutput {
+		return errs.Ok()
+	}
+	conn.ds.output = conn.output
+	conn.ds.published = true
+	if conn.ds.publish != nil {
+		return conn.ds.publish(conn.output)
+	}
+	return errs.Ok()
+}
+
+// IsCommitted reports whether this connection has been committed.
+func (conn *IoDataConn[I, O]) IsCommitted() bool {
+	return conn.committed
+}
+
+// Rollback discards the output.
+func (conn *IoDataConn[I, O]) Rollback(ag *AsyncGroup) errs.Err {
+	conn.output = *new(O)
+	conn.hasOutput = false
+	return errs.Ok()
+}
+
+// OnTxnFailure does nothing.
+func (conn *IoDataConn[I, O]) OnTxnFailure(ag *AsyncGroup, reports []TxnFailureReport) {
+}
+
+// Close does nothing.
+func (conn *IoDataConn[I, O]) Close() {
+}
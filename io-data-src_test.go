@@ -0,0 +1,268 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type GreetInput struct {
+	Name string
+}
+
+type GreetOutput struct {
+	Message string
+}
+
+type GreetData interface {
+	GetName() string
+	SetGreeting(msg string)
+}
+
+type GreetDataAcc struct {
+	DataAcc
+}
+
+func (da *GreetDataAcc) GetName() string {
+	conn, err := GetDataConn[*IoDataConn[GreetInput, GreetOutput]](da, "io")
+	if err.IsNotOk() {
+		return ""
+	}
+	return conn.Input().Name
+}
+
+func (da *GreetDataAcc) SetGreeting(msg string) {
+	conn, err := GetDataConn[*IoDataConn[GreetInput, GreetOutput]](da, "io")
+	if err.IsOk() {
+		conn.SetOutput(GreetOutput{Message: msg})
+	}
+}
+
+type GreetDataHub struct {
+	DataHub
+	*GreetDataAcc
+}
+
+func NewGreetDataHub() GreetDataHub {
+	hub := NewDataHub()
+	return GreetDataHub{DataHub: hub, GreetDataAcc: &GreetDataAcc{DataAcc: hub}}
+}
+
+func GreetLogic(data GreetData) errs.Err {
+	name := data.GetName()
+	if len(name) == 0 {
+		return errs.New("no name")
+	}
+	data.SetGreeting("Hello, " + name)
+	return errs.Ok()
+}
+
+func TestIoDataSrc(t *testing.T) {
+	t.Run("publish output on commit", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		published := make([]GreetOutput, 0)
+		ds := NewIoDataSrc(GreetInput{Name: "foo"}, func(output GreetOutput) errs.Err {
+			published = append(published, output)
+			return errs.Ok()
+		})
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		_, ok := ds.Output()
+		assert.False(t, ok)
+
+		err := Txn(hub, GreetLogic)
+		assert.True(t, err.IsOk())
+
+		output, ok := ds.Output()
+		assert.True(t, ok)
+		assert.Equal(t, output.Message, "Hello, foo")
+		assert.Equal(t, published, []GreetOutput{{Message: "Hello, foo"}})
+	})
+
+	t.Run("discard output on rollback", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		ds := NewIoDataSrc[GreetInput, GreetOutput](GreetInput{Name: "foo"}, nil)
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+		hub.Uses("bar", NewMyDataSrc(1, Failure_Commit, logger))
+
+		err := Txn(hub, func(data GreetDataHub) errs.Err {
+			if err := GreetLogic(data); err.IsNotOk() {
+				return err
+			}
+			_, err := GetDataConn[*MyDataConn](data, "bar")
+			return err
+		})
+		assert.True(t, err.IsNotOk())
+
+		_, ok := ds.Output()
+		assert.False(t, ok)
+	})
+
+	t.Run("fail to publish", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := NewIoDataSrc(GreetInput{Name: "foo"}, func(output GreetOutput) errs.Err {
+			return errs.New("publish error")
+		})
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		err := Txn(hub, GreetLogic)
+		switch rsn := err.Reason().(type) {
+		case FailToPostCommitDataConn:
+			assert.Equal(t, rsn.Errors[0].Name, "io")
+			assert.Equal(t, rsn.Errors[0].Err.Reason(), "publish error")
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		_, ok := ds.Output()
+		assert.True(t, ok)
+	})
+
+	t.Run("publish output on run", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		published := make([]GreetOutput, 0)
+		ds := NewIoDataSrc(GreetInput{Name: "foo"}, func(output GreetOutput) errs.Err {
+			published = append(published, output)
+			return errs.Ok()
+		})
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		err := Run(hub, GreetLogic)
+		assert.True(t, err.IsOk())
+
+		output, ok := ds.Output()
+		assert.True(t, ok)
+		assert.Equal(t, output.Message, "Hello, foo")
+		assert.Equal(t, published, []GreetOutput{{Message: "Hello, foo"}})
+	})
+
+	t.Run("not publish output on run failure", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := NewIoDataSrc[GreetInput, GreetOutput](GreetInput{Name: "foo"}, nil)
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		err := Run(hub, func(data GreetDataHub) errs.Err {
+			data.SetGreeting("Hello")
+			return errs.New("logic error")
+		})
+		assert.Equal(t, err.Reason(), "logic error")
+
+		_, ok := ds.Output()
+		assert.False(t, ok)
+	})
+
+	t.Run("fail to publish on run", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := NewIoDataSrc(GreetInput{Name: "foo"}, func(output GreetOutput) errs.Err {
+			return errs.New("publish error")
+		})
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		err := Run(hub, GreetLogic)
+		switch rsn := err.Reason().(type) {
+		case FailToCompleteRunDataConn:
+			assert.Equal(t, rsn.Errors[0].Name, "io")
+			assert.Equal(t, rsn.Errors[0].Err.Reason(), "publish error")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("run without output", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := NewIoDataSrc[GreetInput, GreetOutput](GreetInput{}, nil)
+
+		hub := NewGreetDataHub()
+		defer hub.Close()
+		hub.Uses("io", ds)
+
+		err := Txn(hub, GreetLogic)
+		assert.Equal(t, err.Reason(), "no name")
+
+		_, ok := ds.Output()
+		assert.False(t, ok)
+	})
+
+	t.Run("data conn", func(t *testing.T) {
+		ds := NewIoDataSrc[int, string](1, nil)
+		assert.True(t, ds.Setup(&AsyncGroup{}).IsOk())
+		defer ds.Close()
+
+		dc, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		conn := dc.(*IoDataConn[int, string])
+		defer conn.Close()
+
+		assert.Equal(t, conn.Input(), 1)
+		_, ok := conn.Output()
+		assert.False(t, ok)
+		conn.SetOutput("a")
+		conn.SetOutput("b")
+		output, ok := conn.Output()
+		assert.True(t, ok)
+		assert.Equal(t, output, "b")
+
+		ag := &AsyncGroup{}
+		assert.True(t, conn.PreCommit(ag).IsOk())
+		assert.False(t, conn.IsCommitted())
+		assert.True(t, conn.Commit(ag).IsOk())
+		assert.True(t, conn.IsCommitted())
+		assert.True(t, conn.PostCommit(ag).IsOk())
+		conn.OnTxnFailure(ag, nil)
+
+		output, ok = ds.Output()
+		assert.True(t, ok)
+		assert.Equal(t, output, "b")
+	})
+
+	t.Run("get data conn by type", func(t *testing.T) {
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("io", NewIoDataSrc[int, string](1, nil))
+
+		err := Run(hub, func(data any) errs.Err {
+			conn, err := GetDataConnByType[*IoDataConn[int, string]](data)
+			if err.IsNotOk() {
+				return err
+			}
+			assert.Equal(t, conn.Input(), 1)
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	})
+}
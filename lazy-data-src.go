@@ -0,0 +1,125 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// FailToSetupLazyDataSrc represents an error reason indicating that a data source registered
+	// with Lazy failed to initialize on the first retrieval of its data connection. It wraps the
+	// errors returned by the setup of the data source and by its asynchronous tasks.
+	// Since a failed setup is retried on the next retrieval, this error is returned by every
+	// retrieval until the setup succeeds.
+	FailToSetupLazyDataSrc struct {
+		Name   string
+		Errors []ErrEntry
+	}
+)
+
+// Lazy wraps a data source so that its Setup is deferred until a data connection is first
+// retrieved from it with GetDataConn or GetDataConnByType, instead of being run by Setup or at
+// the beginning of Run and Txn.
+//
+// The returned data source is registered by Uses or DataHub.Uses as usual. Its Setup is run
+// once even if the data source is global and shared by DataHubs running concurrently, and the
+// wrapped data source is closed only if its Setup has been run successfully.
+// If the Setup fails, it is run again on the next retrieval of a data connection, so that the
+// data source can recover from a transient failure such as a network outage.
+func Lazy(ds DataSrc) DataSrc {
+	return &lazyDataSrc{ds: ds}
+}
+
+type lazyDataSrc struct {
+	ds    DataSrc
+	mutex sync.Mutex
+	ok    bool
+}
+
+func (ds *lazyDataSrc) Setup(ag *AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+
+func (ds *lazyDataSrc) Close() {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if ds.ok {
+		ds.ds.Close()
+		ds.ok = false
+	}
+}
+
+func (ds *lazyDataSrc) CreateDataConn() (DataConn, errs.Err) {
+	return ds.ds.CreateDataConn()
+}
+
+func (ds *lazyDataSrc) isSetUp() bool {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return ds.ok
+}
+
+func (ds *lazyDataSrc) wrappedDataSrc() any {
+	return ds.ds
+}
+
+// setupIfNotYet runs Setup of the wrapped data source if it has not succeeded yet, and returns
+// the errors of the setup and whether this call ran it.
+func (ds *lazyDataSrc) setupIfNotYet(name string) (errors []ErrEntry, ran bool) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if ds.ok {
+		return nil, false
+	}
+
+	ag := AsyncGroup{}
+	ag._name = name
+	if err := ds.ds.Setup(&ag); err.IsNotOk() {
+		ag.addErr(ag._index, ag._name, err)
+	}
+	errors = ag.join()
+	ds.ok = len(errors) == 0
+	return errors, true
+}
+
+func isLazyDataSrc(ds DataSrc) bool {
+	_, ok := ds.(*lazyDataSrc)
+	return ok
+}
+
+func (hub *dataHubImpl) setupLazyDataSrc(dsCont dataSrcContainer) errs.Err {
+	lazy, ok := dsCont.ds.(*lazyDataSrc)
+	if !ok {
+		return errs.Ok()
+	}
+
+	start := time.Now()
+	errors, ran := lazy.setupIfNotYet(dsCont.name)
+
+	var err errs.Err
+	if len(errors) > 0 {
+		err = errs.New(FailToSetupLazyDataSrc{Name: dsCont.name, Errors: errors})
+	} else {
+		err = errs.Ok()
+	}
+
+	if ran && hub.observer.isActive() {
+		hub.observer.notify(Event{
+			Phase:        PhaseSetupDataSrc,
+			DataConnName: dsCont.name,
+			DataSrcType:  dataSrcTypeNameOf(dsCont.ds),
+			Start:        start,
+			Duration:     time.Since(start),
+			Err:          err,
+		})
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package sabi
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type CountingDataSrc struct {
+	setups   atomic.Int32
+	closes   atomic.Int32
+	failures int32
+}
+
+func (ds *CountingDataSrc) Setup(ag *AsyncGroup) errs.Err {
+	if ds.setups.Add(1) <= ds.failures {
+		return errs.New("setup error")
+	}
+	return errs.Ok()
+}
+
+func (ds *CountingDataSrc) Close() {
+	ds.closes.Add(1)
+}
+
+func (ds *CountingDataSrc) CreateDataConn() (DataConn, errs.Err) {
+	return &BadDataConn{}, errs.Ok()
+}
+
+func TestLazyDataSrc(t *testing.T) {
+	t.Run("not set up if not used", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", Lazy(NewMyDataSrc(1, Failure_None, logger)))
+			hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+			err := Txn(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "bar")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("set up on first use", func(t *testing.T) {
+		logger := list.New()
+
+		var events []Event
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+			hub.AddListener(ListenerFunc(func(ev Event) {
+				if ev.Phase == PhaseSetupDataSrc {
+					events = append(events, ev)
+				}
+			}))
+
+			hub.Uses("foo", Lazy(NewMyDataSrc(1, Failure_None, logger)))
+
+			for i := 0; i < 2; i++ {
+				err := Run(hub, func(data any) errs.Err {
+					logger.PushBack("execute logic")
+					_, err := GetDataConn[*MyDataConn](data, "foo")
+					return err
+				})
+				assert.True(t, err.IsOk())
+			}
+		}()
+
+		assert.Len(t, events, 1)
+		assert.Equal(t, events[0].DataConnName, "foo")
+		assert.Equal(t, events[0].DataSrcType, "*sabi.MyDataSrc")
+		assert.True(t, events[0].Err.IsOk())
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "execute logic")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "execute logic")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("fail to set up on every use", func(t *testing.T) {
+		logger := list.New()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", Lazy(NewMyDataSrc(1, Failure_Setup, logger)))
+
+			for i := 0; i < 2; i++ {
+				err := Txn(hub, func(data any) errs.Err {
+					_, err := GetDataConn[*MyDataConn](data, "foo")
+					return err
+				})
+				assert.True(t, err.IsNotOk())
+				switch rsn := err.Reason().(type) {
+				case FailToSetupLazyDataSrc:
+					assert.Equal(t, rsn.Name, "foo")
+					assert.Len(t, rsn.Errors, 1)
+					assert.Equal(t, rsn.Errors[0].Name, "foo")
+					assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
+				default:
+					assert.Fail(t, err.Error())
+				}
+			}
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1 failed")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1 failed")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("global and retry to set up after failure", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := &CountingDataSrc{failures: 1}
+		Uses("foo", Lazy(ds))
+
+		func() {
+			assert.True(t, Setup().IsOk())
+			defer Shutdown()
+
+			logic := func(data any) errs.Err {
+				_, err := GetDataConn[*BadDataConn](data, "foo")
+				return err
+			}
+
+			err := Run(NewDataHub(), logic)
+			switch rsn := err.Reason().(type) {
+			case FailToSetupLazyDataSrc:
+				assert.Equal(t, rsn.Name, "foo")
+				assert.Len(t, rsn.Errors, 1)
+				assert.Equal(t, rsn.Errors[0].Err.Reason(), "setup error")
+			default:
+				assert.Fail(t, err.Error())
+			}
+			assert.Equal(t, ds.setups.Load(), int32(1))
+
+			err = Run(NewDataHub(), logic)
+			assert.True(t, err.IsOk())
+			assert.Equal(t, ds.setups.Load(), int32(2))
+
+			err = Run(NewDataHub(), logic)
+			assert.True(t, err.IsOk())
+			assert.Equal(t, ds.setups.Load(), int32(2))
+			assert.Equal(t, ds.closes.Load(), int32(0))
+		}()
+
+		assert.Equal(t, ds.closes.Load(), int32(1))
+	})
+
+	t.Run("global and set up once across concurrent hubs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := &CountingDataSrc{}
+		Uses("foo", Lazy(ds))
+
+		func() {
+			assert.True(t, SetupWithOrder("foo").IsOk())
+			defer Shutdown()
+			assert.Equal(t, ds.setups.Load(), int32(0))
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					hub := NewDataHub()
+					defer hub.Close()
+					err := Run(hub, func(data any) errs.Err {
+						_, err := GetDataConn[*BadDataConn](data, "foo")
+						return err
+					})
+					assert.True(t, err.IsOk())
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, ds.setups.Load(), int32(1))
+			assert.Equal(t, ds.closes.Load(), int32(0))
+		}()
+
+		assert.Equal(t, ds.closes.Load(), int32(1))
+	})
+
+	t.Run("global and never used", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		ds := &CountingDataSrc{}
+		Uses("foo", Lazy(ds))
+
+		func() {
+			assert.True(t, Setup().IsOk())
+			defer Shutdown()
+		}()
+
+		assert.Equal(t, ds.setups.Load(), int32(0))
+		assert.Equal(t, ds.closes.Load(), int32(0))
+	})
+}
@@ -0,0 +1,185 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// LeaksDetected represents an error reason indicating that CheckLeaks found DataHubs or
+	// DataConns which have been created but not closed yet.
+	LeaksDetected struct {
+		Leaks []Leak
+	}
+)
+
+// LeakKind represents the kind of an object reported as a Leak.
+type LeakKind uint8
+
+// The following constants represent the kinds of leaked objects.
+const (
+	// LeakedDataHub indicates that a DataHub was not closed with DataHub.Close.
+	LeakedDataHub LeakKind = iota
+	// LeakedDataConn indicates that a DataConn was not closed, for example because its DataHub was
+	// still running Run or Txn.
+	LeakedDataConn
+)
+
+// String returns the string representation of the LeakKind.
+func (kind LeakKind) String() string {
+	var s string
+	switch kind {
+	case LeakedDataHub:
+		s = "LeakedDataHub"
+	case LeakedDataConn:
+		s = "LeakedDataConn"
+	}
+	return s
+}
+
+// Leak is a DataHub or DataConn which has been created but not closed.
+type Leak struct {
+	// Kind is the kind of the object.
+	Kind LeakKind
+	// Name is the name of the data source from which the DataConn was created. It is empty for a
+	// DataHub.
+	Name string
+	// DataConnType is the type name of the DataConn. It is empty for a DataHub.
+	DataConnType string
+	// Stack is the stack trace of the goroutine at the time the object was created.
+	Stack string
+}
+
+type leakTracker struct {
+	mutex   sync.Mutex
+	lastId  uint64
+	objects map[uint64]Leak
+}
+
+var (
+	globalLeakTracker *leakTracker
+	globalLeakHandler func([]Leak)
+)
+
+// DetectLeaks enables the detection of DataHubs and DataConns which are created but never closed,
+// and sets the function to which Shutdown and ShutdownWithTimeout pass the detected leaks. Passing
+// nil disables the detection, which is the default.
+//
+// While the detection is enabled, the stack trace at the creation of every DataHub and DataConn is
+// recorded, so this feature is intended for debugging and testing. The objects which are not
+// closed yet can also be checked at any time with CheckLeaks, for example in TestMain.
+// Like Uses, this setting must occur before Setup is called.
+func DetectLeaks(handler func(leaks []Leak)) {
+	if globalDataSrcsFixed.Load() {
+		return
+	}
+
+	globalLeakHandler = handler
+	if handler != nil {
+		globalLeakTracker = &leakTracker{objects: make(map[uint64]Leak)}
+	} else {
+		globalLeakTracker = nil
+	}
+}
+
+// CheckLeaks returns an error with the reason LeaksDetected if there are DataHubs or DataConns
+// which have been created but not closed yet, in the order of their creation.
+// This function always returns an ok if the detection is not enabled by DetectLeaks.
+//
+// The following is an example of checking leaks after running all tests:
+//
+//	func TestMain(m *testing.M) {
+//		sabi.DetectLeaks(func([]sabi.Leak) {})
+//		code := m.Run()
+//		if err := sabi.CheckLeaks(); err.IsNotOk() {
+//			fmt.Println(err)
+//			code = 1
+//		}
+//		os.Exit(code)
+//	}
+func CheckLeaks() errs.Err {
+	if leaks := globalLeakTracker.leaks(); len(leaks) > 0 {
+		return errs.New(LeaksDetected{Leaks: leaks})
+	}
+	return errs.Ok()
+}
+
+func reportLeaks() {
+	if leaks := globalLeakTracker.leaks(); len(leaks) > 0 {
+		globalLeakHandler(leaks)
+	}
+}
+
+func (tracker *leakTracker) track(kind LeakKind, name, dataConnType string) uint64 {
+	if tracker == nil {
+		return 0
+	}
+
+	// Skips the frames of runtime.Callers, captureStack and this method.
+	stack := captureStack(3)
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.lastId++
+	tracker.objects[tracker.lastId] = Leak{
+		Kind: kind, Name: name, DataConnType: dataConnType, Stack: stack}
+	return tracker.lastId
+}
+
+func (tracker *leakTracker) untrack(id uint64) {
+	if tracker == nil || id == 0 {
+		return
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	delete(tracker.objects, id)
+}
+
+func (tracker *leakTracker) leaks() []Leak {
+	if tracker == nil {
+		return nil
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	ids := make([]uint64, 0, len(tracker.objects))
+	for id := range tracker.objects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	leaks := make([]Leak, len(ids))
+	for i, id := range ids {
+		leaks[i] = tracker.objects[id]
+	}
+	return leaks
+}
+
+func captureStack(skip int) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,183 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func TestLeakKind(t *testing.T) {
+	assert.Equal(t, LeakedDataHub.String(), "LeakedDataHub")
+	assert.Equal(t, LeakedDataConn.String(), "LeakedDataConn")
+}
+
+func TestDetectLeaks(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		NewDataHub()
+
+		assert.True(t, CheckLeaks().IsOk())
+		Shutdown()
+	})
+
+	t.Run("no leak", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		var reported [][]Leak
+		DetectLeaks(func(leaks []Leak) { reported = append(reported, leaks) })
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			err := Txn(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		assert.True(t, CheckLeaks().IsOk())
+		Shutdown()
+		assert.Len(t, reported, 0)
+	})
+
+	t.Run("unclosed DataHub", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		var reported [][]Leak
+		DetectLeaks(func(leaks []Leak) { reported = append(reported, leaks) })
+
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		NewDataHub()
+		NewDataHub().Close()
+
+		err = CheckLeaks()
+		switch r := err.Reason().(type) {
+		case LeaksDetected:
+			assert.Len(t, r.Leaks, 1)
+			assert.Equal(t, r.Leaks[0].Kind, LeakedDataHub)
+			assert.Equal(t, r.Leaks[0].Name, "")
+			assert.Equal(t, r.Leaks[0].DataConnType, "")
+			assert.Contains(t, r.Leaks[0].Stack, "github.com/sttk/sabi.NewDataHub\n")
+			assert.Contains(t, r.Leaks[0].Stack, "github.com/sttk/sabi.TestDetectLeaks.func3\n")
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		Shutdown()
+		assert.Len(t, reported, 1)
+		assert.Len(t, reported[0], 1)
+		assert.Equal(t, reported[0][0].Kind, LeakedDataHub)
+	})
+
+	t.Run("unclosed DataConn", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		var reported [][]Leak
+		DetectLeaks(func(leaks []Leak) { reported = append(reported, leaks) })
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		err = Run(hub, func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+
+			err := CheckLeaks()
+			switch r := err.Reason().(type) {
+			case LeaksDetected:
+				assert.Len(t, r.Leaks, 2)
+				assert.Equal(t, r.Leaks[0].Kind, LeakedDataHub)
+				assert.Equal(t, r.Leaks[1].Kind, LeakedDataConn)
+				assert.Equal(t, r.Leaks[1].Name, "foo")
+				assert.Equal(t, r.Leaks[1].DataConnType, "*sabi.MyDataConn")
+				assert.Contains(t, r.Leaks[1].Stack, "github.com/sttk/sabi.GetDataConn[")
+			default:
+				assert.Fail(t, err.Error())
+			}
+
+			Shutdown()
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		assert.Len(t, reported, 1)
+		assert.Len(t, reported[0], 2)
+		assert.Equal(t, reported[0][0].Kind, LeakedDataHub)
+		assert.Equal(t, reported[0][1].Kind, LeakedDataConn)
+
+		hub.Close()
+		assert.True(t, CheckLeaks().IsOk())
+	})
+
+	t.Run("closed DataHub after failing to set up local data sources", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		var reported [][]Leak
+		DetectLeaks(func(leaks []Leak) { reported = append(reported, leaks) })
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			hub.Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+			err := Txn(hub, func(data any) errs.Err { return errs.Ok() })
+			switch err.Reason().(type) {
+			case FailToSetupLocalDataSrcs:
+			default:
+				assert.Fail(t, err.Error())
+			}
+
+			err = Run(hub, func(data any) errs.Err { return errs.Ok() })
+			switch err.Reason().(type) {
+			case FailToSetupLocalDataSrcs:
+			default:
+				assert.Fail(t, err.Error())
+			}
+		}()
+
+		assert.True(t, CheckLeaks().IsOk())
+		Shutdown()
+		assert.Len(t, reported, 0)
+	})
+
+	t.Run("disable after enabled", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		DetectLeaks(func([]Leak) {})
+		DetectLeaks(nil)
+
+		NewDataHub()
+
+		assert.True(t, CheckLeaks().IsOk())
+		Shutdown()
+	})
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+// Phase represents a step in the lifecycle of a DataHub and its transaction, which is notified
+// to Listeners as an Event.
+type Phase uint8
+
+// The following constants represent the phases notified to Listeners.
+const (
+	// PhaseBegin indicates that a DataHub began a Run or Txn, which includes the setup of its
+	// local data sources.
+	PhaseBegin Phase = iota
+	// PhaseGetDataConn indicates that a DataHub created a DataConn from a data source.
+	PhaseGetDataConn
+	// PhasePreCommit indicates that PreCommit of a DataConn was executed.
+	PhasePreCommit
+	// PhaseCommit indicates that Commit of a DataConn was executed.
+	PhaseCommit
+	// PhasePostCommit indicates that PostCommit of a DataConn was executed.
+	PhasePostCommit
+	// PhaseRollback indicates that Rollback of a DataConn was executed.
+	PhaseRollback
+	// PhaseOnTxnFailure indicates that OnTxnFailure of a DataConn was executed.
+	PhaseOnTxnFailure
+	// PhaseEnd indicates that a DataHub ended a Run or Txn and closed its DataConns.
+	PhaseEnd
+	// PhaseSetupDataSrc indicates that Setup of a global or local data source was executed.
+	PhaseSetupDataSrc
+	// PhaseCloseDataSrc indicates that Close of a global or local data source was executed.
+	PhaseCloseDataSrc
+)
+
+// String returns the string representation of the Phase.
+func (phase Phase) String() string {
+	var s string
+	switch phase {
+	case PhaseBegin:
+		s = "Begin"
+	case PhaseGetDataConn:
+		s = "GetDataConn"
+	case PhasePreCommit:
+		s = "PreCommit"
+	case PhaseCommit:
+		s = "Commit"
+	case PhasePostCommit:
+		s = "PostCommit"
+	case PhaseRollback:
+		s = "Rollback"
+	case PhaseOnTxnFailure:
+		s = "OnTxnFailure"
+	case PhaseEnd:
+		s = "End"
+	case PhaseSetupDataSrc:
+		s = "SetupDataSrc"
+	case PhaseCloseDataSrc:
+		s = "CloseDataSrc"
+	}
+	return s
+}
+
+// Event is a notification about a phase in the lifecycle of a DataHub and its transaction.
+//
+// DataConnName and DataConnType are empty for the phases which are not about a specific
+// DataConn, namely PhaseBegin and PhaseEnd. For PhaseSetupDataSrc and PhaseCloseDataSrc,
+// DataConnName is the name of the data source and DataSrcType is set instead of DataConnType,
+// and HubId is zero if the data source is a global one.
+// Report is set only for PhaseRollback and PhaseOnTxnFailure.
+// For PhaseEnd, Err is the result of the Run or Txn.
+// Duration is the time taken by the synchronous part of the phase; tasks added to an AsyncGroup
+// are not included, but their errors are reflected in Err.
+type Event struct {
+	// HubId is the identifier of the DataHub, unique within the process.
+	HubId uint64
+	// Phase is the phase which this event is about.
+	Phase Phase
+	// DataConnName is the name of the data source of the DataConn.
+	DataConnName string
+	// DataConnType is the type name of the DataConn.
+	DataConnType string
+	// DataSrcType is the type name of the data source.
+	DataSrcType string
+	// Start is the time when the phase started.
+	Start time.Time
+	// Duration is the time taken by the phase.
+	Duration time.Duration
+	// Err is the result of the phase.
+	Err errs.Err
+	// Report is the failure report of the transaction for the DataConn.
+	Report *TxnFailureReport
+	// Txn reports whether the DataHub executed Txn rather than Run. It is set only for PhaseEnd.
+	Txn bool
+}
+
+// Listener is an interface to observe the lifecycle of DataHubs and their transactions without
+// modifying DataConn implementations, which is useful to layer logging, metrics and tracing.
+//
+// OnEvent is called synchronously on the goroutine executing Run or Txn, so it should return
+// quickly.
+type Listener interface {
+	OnEvent(ev Event)
+}
+
+// ListenerFunc is an adapter to allow the use of an ordinary function as a Listener.
+type ListenerFunc func(ev Event)
+
+// OnEvent calls f(ev).
+func (f ListenerFunc) OnEvent(ev Event) {
+	f(ev)
+}
+
+var (
+	globalListeners []Listener
+	hubIdCounter    atomic.Uint64
+)
+
+// AddListener registers a global Listener which observes all DataHubs created after this call.
+// Like Uses, this registration must occur before Setup is called.
+func AddListener(l Listener) {
+	if !globalDataSrcsFixed.Load() {
+		globalListeners = append(globalListeners, l)
+	}
+}
+
+type observer struct {
+	hubId         uint64
+	listeners     []Listener
+	logger        *slog.Logger
+	tracer        Tracer
+	ctx           context.Context
+	spanCtx       context.Context
+	span          Span
+	metrics       MetricsSink
+	dataConnCount int
+	mutex         sync.Mutex
+}
+
+func newObserver() observer {
+	listeners := make([]Listener, len(globalListeners))
+	copy(listeners, globalListeners)
+	return observer{
+		hubId:     hubIdCounter.Add(1),
+		listeners: listeners,
+		logger:    globalLogger,
+		tracer:    globalTracer,
+		metrics:   globalMetricsSink,
+	}
+}
+
+func newGlobalObserver() *observer {
+	return &observer{
+		hubId:     0,
+		listeners: globalListeners,
+		logger:    globalLogger,
+		tracer:    globalTracer,
+		metrics:   globalMetricsSink,
+	}
+}
+
+func (o *observer) isActive() bool {
+	return o != nil &&
+		(len(o.listeners) > 0 || o.logger != nil || o.tracer != nil || o.metrics != nil)
+}
+
+func (o *observer) notify(ev Event) {
+	if !o.isActive() {
+		return
+	}
+	// Events can be notified concurrently by goroutines sharing a DataHub, see Parallel.
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	ev.HubId = o.hubId
+	for _, l := range o.listeners {
+		l.OnEvent(ev)
+	}
+	if o.logger != nil {
+		logEvent(o.logger, ev)
+	}
+	if o.tracer != nil {
+		o.traceEvent(ev)
+	}
+	if o.metrics != nil {
+		o.measureEvent(ev)
+	}
+}
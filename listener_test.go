@@ -0,0 +1,232 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type eventLog struct {
+	events []Event
+}
+
+func (l *eventLog) OnEvent(ev Event) {
+	l.events = append(l.events, ev)
+}
+
+func (l *eventLog) phases() []string {
+	a := make([]string, 0, len(l.events))
+	for _, ev := range l.events {
+		s := ev.Phase.String()
+		if len(ev.DataConnName) > 0 {
+			s += " " + ev.DataConnName
+		}
+		if ev.Err.IsNotOk() {
+			s += " failed"
+		}
+		a = append(a, s)
+	}
+	return a
+}
+
+func TestListener(t *testing.T) {
+	t.Run("Phase String", func(t *testing.T) {
+		assert.Equal(t, PhaseBegin.String(), "Begin")
+		assert.Equal(t, PhaseGetDataConn.String(), "GetDataConn")
+		assert.Equal(t, PhasePreCommit.String(), "PreCommit")
+		assert.Equal(t, PhaseCommit.String(), "Commit")
+		assert.Equal(t, PhasePostCommit.String(), "PostCommit")
+		assert.Equal(t, PhaseRollback.String(), "Rollback")
+		assert.Equal(t, PhaseOnTxnFailure.String(), "OnTxnFailure")
+		assert.Equal(t, PhaseEnd.String(), "End")
+		assert.Equal(t, PhaseSetupDataSrc.String(), "SetupDataSrc")
+		assert.Equal(t, PhaseCloseDataSrc.String(), "CloseDataSrc")
+		assert.Equal(t, Phase(99).String(), "")
+	})
+
+	t.Run("AddListener", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		el := &eventLog{}
+		AddListener(el)
+		assert.Len(t, globalListeners, 1)
+
+		assert.True(t, Setup().IsOk())
+		defer Shutdown()
+
+		AddListener(el)
+		assert.Len(t, globalListeners, 1)
+
+		hub1 := NewDataHub()
+		defer hub1.Close()
+		hub2 := NewDataHub()
+		defer hub2.Close()
+
+		hubImpl1 := hub1.(*dataHubImpl)
+		hubImpl2 := hub2.(*dataHubImpl)
+		assert.Len(t, hubImpl1.observer.listeners, 1)
+		assert.NotEqual(t, hubImpl1.observer.hubId, hubImpl2.observer.hubId)
+
+		hub1.AddListener(ListenerFunc(func(ev Event) {}))
+		assert.Len(t, hubImpl1.observer.listeners, 2)
+		assert.Len(t, hubImpl2.observer.listeners, 1)
+		assert.Len(t, globalListeners, 1)
+	})
+
+	t.Run("txn and ok", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		gl := &eventLog{}
+		AddListener(gl)
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		hl := &eventLog{}
+		hub.AddListener(hl)
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			_, err := GetDataConn[*MyDataConn](data, "bar")
+			return err
+		})
+		assert.True(t, err.IsOk())
+
+		expected := []string{
+			"SetupDataSrc foo",
+			"SetupDataSrc bar",
+			"Begin",
+			"GetDataConn foo",
+			"GetDataConn bar",
+			"PreCommit foo",
+			"PreCommit bar",
+			"Commit foo",
+			"Commit bar",
+			"PostCommit foo",
+			"PostCommit bar",
+			"End",
+		}
+		assert.Equal(t, gl.phases(), expected)
+		assert.Equal(t, hl.phases(), expected)
+
+		hubId := hub.(*dataHubImpl).observer.hubId
+		for i, ev := range gl.events {
+			assert.Equal(t, ev.HubId, hubId)
+			assert.False(t, ev.Start.IsZero())
+			assert.True(t, ev.Duration >= 0)
+			switch ev.Phase {
+			case PhaseBegin, PhaseEnd:
+				assert.Equal(t, ev.DataConnType, "")
+				assert.Equal(t, ev.DataSrcType, "")
+			case PhaseSetupDataSrc:
+				assert.Equal(t, ev.DataConnType, "")
+				assert.Equal(t, ev.DataSrcType, "*sabi.MyDataSrc")
+			default:
+				assert.Equal(t, ev.DataConnType, "*sabi.MyDataConn")
+			}
+			assert.Equal(t, hl.events[i], ev)
+		}
+	})
+
+	t.Run("txn but failed to commit", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		el := &eventLog{}
+		hub.AddListener(el)
+		hub.Uses("foo", NewMyDataSrc(1, Failure_Commit, logger))
+		hub.Uses("bar", NewMyDataSrc(2, Failure_Rollback, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			_, err := GetDataConn[*MyDataConn](data, "bar")
+			return err
+		})
+		assert.True(t, err.IsNotOk())
+
+		assert.Equal(t, el.phases(), []string{
+			"SetupDataSrc foo",
+			"SetupDataSrc bar",
+			"Begin",
+			"GetDataConn foo",
+			"GetDataConn bar",
+			"PreCommit foo",
+			"PreCommit bar",
+			"Commit foo failed",
+			"Rollback foo",
+			"Rollback bar failed",
+			"OnTxnFailure foo",
+			"OnTxnFailure bar",
+			"End failed",
+		})
+		assert.Equal(t, el.events[7].Err.Reason(), "commit error")
+		assert.Equal(t, el.events[9].Err.Reason(), "rollback error")
+		assert.Nil(t, el.events[7].Report)
+		assert.Equal(t, el.events[8].Report.DataConnName, "foo")
+		assert.Equal(t, el.events[8].Report.Cause.State, CommitFailure)
+		assert.Equal(t, el.events[8].Report.Rollback.State, NoneByRolledBack)
+		assert.Equal(t, el.events[9].Report.DataConnName, "bar")
+		assert.Equal(t, el.events[9].Report.Rollback.State, RollbackFailure)
+		assert.Equal(t, el.events[10].Report.DataConnName, "foo")
+		assert.Equal(t, el.events[11].Report.DataConnName, "bar")
+	})
+
+	t.Run("run but failed to setup or create data conn", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			el := &eventLog{}
+			hub.AddListener(el)
+			hub.Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+			err := Run(hub, func(data any) errs.Err { return errs.Ok() })
+			assert.True(t, err.IsNotOk())
+
+			assert.Equal(t, el.phases(), []string{"SetupDataSrc foo failed", "Begin failed"})
+		}()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+
+			el := &eventLog{}
+			hub.AddListener(el)
+			hub.Uses("foo", NewMyDataSrc(1, Failure_CreateDataConn, logger))
+
+			err := Run(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsNotOk())
+
+			assert.Equal(t, el.phases(), []string{
+				"SetupDataSrc foo", "Begin", "GetDataConn foo failed", "End failed"})
+			assert.Equal(t, el.events[2].DataConnType, "*sabi.MyDataConn")
+		}()
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"log/slog"
+)
+
+// The following constants are the keys of the attributes of log records emitted by sabi.
+// These keys are stable, so log pipelines can filter and alert on them.
+const (
+	// LogKeyHubId is the key of the identifier of the DataHub. It is zero for global data
+	// sources.
+	LogKeyHubId = "sabi.hub_id"
+	// LogKeyPhase is the key of the phase name, which is the string representation of a Phase.
+	LogKeyPhase = "sabi.phase"
+	// LogKeyName is the key of the name of the data source.
+	LogKeyName = "sabi.name"
+	// LogKeyDataConnType is the key of the type name of the DataConn.
+	LogKeyDataConnType = "sabi.data_conn_type"
+	// LogKeyDataSrcType is the key of the type name of the data source.
+	LogKeyDataSrcType = "sabi.data_src_type"
+	// LogKeyDuration is the key of the time taken by the phase.
+	LogKeyDuration = "sabi.duration"
+	// LogKeyError is the key of the error of the phase.
+	LogKeyError = "sabi.error"
+	// LogKeyCauseState is the key of the string representation of the TxnFailureCauseState.
+	LogKeyCauseState = "sabi.cause_state"
+	// LogKeyRollbackState is the key of the string representation of the
+	// TxnFailureRollbackState.
+	LogKeyRollbackState = "sabi.rollback_state"
+)
+
+// LogMessage is the message of all log records emitted by sabi.
+const LogMessage = "sabi"
+
+var globalLogger *slog.Logger
+
+// SetLogger sets a *slog.Logger which receives structured log records about setup and close
+// of data sources, creation of DataConns, each commit phase and rollback outcomes of all
+// DataHubs created after this call. Passing nil disables logging, which is the default.
+// Like Uses, this setting must occur before Setup is called.
+//
+// The levels of log records are as follows: successful phases are logged at the debug level,
+// except that setup and close of data sources are logged at the info level; rollbacks and
+// OnTxnFailure calls are logged at the warn level; and failed phases, including rollbacks with
+// RollbackFailure, are logged at the error level. The end of a failed Run or Txn is logged at
+// the warn level, since its error is also returned to the caller.
+func SetLogger(logger *slog.Logger) {
+	if !globalDataSrcsFixed.Load() {
+		globalLogger = logger
+	}
+}
+
+func logEvent(logger *slog.Logger, ev Event) {
+	level := slog.LevelDebug
+	switch ev.Phase {
+	case PhaseSetupDataSrc, PhaseCloseDataSrc:
+		level = slog.LevelInfo
+	case PhaseRollback, PhaseOnTxnFailure:
+		level = slog.LevelWarn
+	}
+	if ev.Err.IsNotOk() {
+		if ev.Phase == PhaseEnd {
+			level = slog.LevelWarn
+		} else {
+			level = slog.LevelError
+		}
+	}
+	if ev.Report != nil && ev.Report.Rollback.State == RollbackFailure {
+		level = slog.LevelError
+	}
+
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 9)
+	attrs = append(attrs,
+		slog.Uint64(LogKeyHubId, ev.HubId),
+		slog.String(LogKeyPhase, ev.Phase.String()),
+	)
+	if len(ev.DataConnName) > 0 {
+		attrs = append(attrs, slog.String(LogKeyName, ev.DataConnName))
+	}
+	if len(ev.DataConnType) > 0 {
+		attrs = append(attrs, slog.String(LogKeyDataConnType, ev.DataConnType))
+	}
+	if len(ev.DataSrcType) > 0 {
+		attrs = append(attrs, slog.String(LogKeyDataSrcType, ev.DataSrcType))
+	}
+	attrs = append(attrs, slog.Duration(LogKeyDuration, ev.Duration))
+	if ev.Err.IsNotOk() {
+		attrs = append(attrs, slog.String(LogKeyError, ev.Err.Error()))
+	}
+	if ev.Report != nil {
+		attrs = append(attrs,
+			slog.String(LogKeyCauseState, ev.Report.Cause.State.String()),
+			slog.String(LogKeyRollbackState, ev.Report.Rollback.State.String()),
+		)
+	}
+
+	logger.LogAttrs(ctx, level, LogMessage, attrs...)
+}
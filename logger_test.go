@@ -0,0 +1,201 @@
+package sabi
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func newJsonLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func parseLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	recs := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		m := make(map[string]any)
+		assert.Nil(t, json.Unmarshal([]byte(line), &m))
+		recs = append(recs, m)
+	}
+	return recs
+}
+
+func logSummary(recs []map[string]any) []string {
+	a := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		s := rec["level"].(string) + " " + rec[LogKeyPhase].(string)
+		if name, ok := rec[LogKeyName]; ok {
+			s += " " + name.(string)
+		}
+		a = append(a, s)
+	}
+	return a
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("global data srcs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		buf := &bytes.Buffer{}
+		SetLogger(newJsonLogger(buf))
+
+		logger := list.New()
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+		assert.True(t, Setup().IsOk())
+		SetLogger(nil)
+		assert.NotNil(t, globalLogger)
+		Shutdown()
+
+		recs := parseLogRecords(t, buf)
+		assert.Equal(t, logSummary(recs), []string{
+			"INFO SetupDataSrc foo",
+			"INFO SetupDataSrc bar",
+			"INFO CloseDataSrc bar",
+			"INFO CloseDataSrc foo",
+		})
+		for _, rec := range recs {
+			assert.Equal(t, rec["msg"], LogMessage)
+			assert.Equal(t, rec[LogKeyHubId], float64(0))
+			assert.Equal(t, rec[LogKeyDataSrcType], "*sabi.MyDataSrc")
+			assert.NotNil(t, rec[LogKeyDuration])
+			assert.Nil(t, rec[LogKeyError])
+		}
+	})
+
+	t.Run("fail to setup global data srcs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		buf := &bytes.Buffer{}
+		SetLogger(newJsonLogger(buf))
+
+		logger := list.New()
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_Setup, logger))
+
+		assert.True(t, Setup().IsNotOk())
+
+		recs := parseLogRecords(t, buf)
+		assert.Equal(t, logSummary(recs), []string{
+			"INFO SetupDataSrc foo",
+			"ERROR SetupDataSrc bar",
+			"INFO CloseDataSrc foo",
+		})
+		assert.Contains(t, recs[1][LogKeyError], "reason:setup error")
+	})
+
+	t.Run("txn with global logger", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		buf := &bytes.Buffer{}
+		SetLogger(newJsonLogger(buf))
+
+		logger := list.New()
+
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+			hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+			err := Txn(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		recs := parseLogRecords(t, buf)
+		assert.Equal(t, logSummary(recs), []string{
+			"INFO SetupDataSrc foo",
+			"DEBUG Begin",
+			"DEBUG GetDataConn foo",
+			"DEBUG PreCommit foo",
+			"DEBUG Commit foo",
+			"DEBUG PostCommit foo",
+			"DEBUG End",
+			"INFO CloseDataSrc foo",
+		})
+		assert.Equal(t, recs[2][LogKeyDataConnType], "*sabi.MyDataConn")
+		assert.True(t, recs[1][LogKeyHubId].(float64) > 0)
+	})
+
+	t.Run("txn with hub logger and rollback failure", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		globalBuf := &bytes.Buffer{}
+		SetLogger(newJsonLogger(globalBuf))
+
+		logger := list.New()
+
+		buf := &bytes.Buffer{}
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+			hub.SetLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+			hub.Uses("foo", NewMyDataSrc(1, Failure_Rollback, logger))
+			hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+			err := Txn(hub, func(data any) errs.Err {
+				if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+					return err
+				}
+				if _, err := GetDataConn[*MyDataConn](data, "bar"); err.IsNotOk() {
+					return err
+				}
+				return errs.New("logic error")
+			})
+			assert.Equal(t, err.Reason(), "logic error")
+		}()
+
+		assert.Equal(t, globalBuf.Len(), 0)
+
+		recs := parseLogRecords(t, buf)
+		assert.Equal(t, logSummary(recs), []string{
+			"INFO SetupDataSrc foo",
+			"INFO SetupDataSrc bar",
+			"ERROR Rollback foo",
+			"WARN Rollback bar",
+			"ERROR OnTxnFailure foo",
+			"WARN OnTxnFailure bar",
+			"WARN End",
+			"INFO CloseDataSrc bar",
+			"INFO CloseDataSrc foo",
+		})
+		assert.Equal(t, recs[2][LogKeyRollbackState], "RollbackFailure")
+		assert.Equal(t, recs[2][LogKeyCauseState], "NoneByUncommitted")
+		assert.Contains(t, recs[2][LogKeyError], "reason:rollback error")
+		assert.Equal(t, recs[3][LogKeyRollbackState], "NoneByRolledBack")
+		assert.Nil(t, recs[3][LogKeyError])
+		assert.Equal(t, recs[4][LogKeyRollbackState], "RollbackFailure")
+	})
+
+	t.Run("disable logging for a hub", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		buf := &bytes.Buffer{}
+		SetLogger(newJsonLogger(buf))
+
+		hub := NewDataHub()
+		hub.SetLogger(nil)
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, list.New()))
+		assert.True(t, Run(hub, func(data any) errs.Err { return errs.Ok() }).IsOk())
+		hub.Close()
+
+		assert.Equal(t, buf.Len(), 0)
+	})
+}
@@ -0,0 +1,258 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"expvar"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricsSink is an interface to receive metrics about transactions, connections and setup of
+// data sources. Implementations must be safe for concurrent use.
+type MetricsSink interface {
+	// AddCounter adds delta to the counter identified by the name and labels.
+	AddCounter(name string, labels map[string]string, delta int64)
+	// Observe records the value to the histogram identified by the name and labels.
+	Observe(name string, labels map[string]string, value float64)
+}
+
+// The following constants are the names of the metrics sent to a MetricsSink.
+const (
+	// MetricRuns is the name of the counter of Runs, labeled with MetricLabelOutcome, whose
+	// value is MetricOutcomeOk or MetricOutcomeError.
+	MetricRuns = "sabi_runs_total"
+	// MetricTxns is the name of the counter of Txns, labeled with MetricLabelOutcome, whose value
+	// is MetricOutcomeCommitted, MetricOutcomeRolledBack or MetricOutcomePostCommitFailed.
+	MetricTxns = "sabi_txns_total"
+	// MetricTxnFailures is the name of the counter of failure reports of DataConns in failed
+	// Txns, labeled with MetricLabelDataConnType, MetricLabelCauseState and
+	// MetricLabelRecovery. The recovery is the result of TxnFailureReport.RecoveryForCommit.
+	MetricTxnFailures = "sabi_txn_failures_total"
+	// MetricPhaseSeconds is the name of the histogram of the time taken by each phase of each
+	// DataConn in seconds, labeled with MetricLabelPhase, MetricLabelDataConnType and
+	// MetricLabelOutcome, whose value is MetricOutcomeOk or MetricOutcomeError.
+	MetricPhaseSeconds = "sabi_phase_seconds"
+	// MetricDataConnsPerHub is the name of the histogram of the number of DataConns created in
+	// a Run or Txn.
+	MetricDataConnsPerHub = "sabi_data_conns_per_hub"
+	// MetricDataSrcSetupSeconds is the name of the histogram of the time taken by Setup of each
+	// data source in seconds, labeled with MetricLabelDataSrcType and MetricLabelOutcome, whose
+	// value is MetricOutcomeOk or MetricOutcomeError.
+	MetricDataSrcSetupSeconds = "sabi_data_src_setup_seconds"
+)
+
+// The following constants are the names of the labels of metrics.
+const (
+	MetricLabelOutcome      = "outcome"
+	MetricLabelPhase        = "phase"
+	MetricLabelDataConnType = "data_conn_type"
+	MetricLabelDataSrcType  = "data_src_type"
+	MetricLabelCauseState   = "cause_state"
+	MetricLabelRecovery     = "recovery"
+)
+
+// The following constants are the values of the label MetricLabelOutcome.
+const (
+	MetricOutcomeOk               = "ok"
+	MetricOutcomeError            = "error"
+	MetricOutcomeCommitted        = "committed"
+	MetricOutcomeRolledBack       = "rolled_back"
+	MetricOutcomePostCommitFailed = "post_commit_failed"
+)
+
+var globalMetricsSink MetricsSink
+
+// SetMetricsSink sets a MetricsSink which receives metrics of all DataHubs created after this
+// call and of the setup of data sources. Passing nil disables metrics, which is the default.
+// Like Uses, this setting must occur before Setup is called.
+//
+// Runs and Txns which fail to set up their local data sources are not counted in MetricRuns
+// and MetricTxns, but the failures are recorded in MetricDataSrcSetupSeconds.
+func SetMetricsSink(sink MetricsSink) {
+	if !globalDataSrcsFixed.Load() {
+		globalMetricsSink = sink
+	}
+}
+
+func outcomeOf(ev Event) string {
+	if ev.Err.IsOk() {
+		return MetricOutcomeOk
+	}
+	return MetricOutcomeError
+}
+
+func (o *observer) measureEvent(ev Event) {
+	switch ev.Phase {
+	case PhaseSetupDataSrc:
+		o.metrics.Observe(MetricDataSrcSetupSeconds, map[string]string{
+			MetricLabelDataSrcType: ev.DataSrcType,
+			MetricLabelOutcome:     outcomeOf(ev),
+		}, ev.Duration.Seconds())
+	case PhaseBegin:
+		o.dataConnCount = 0
+	case PhaseEnd:
+		if ev.Txn {
+			outcome := MetricOutcomeCommitted
+			if ev.Err.IsNotOk() {
+				switch ev.Err.Reason().(type) {
+				case FailToPostCommitDataConn:
+					outcome = MetricOutcomePostCommitFailed
+				default:
+					outcome = MetricOutcomeRolledBack
+				}
+			}
+			o.metrics.AddCounter(MetricTxns, map[string]string{MetricLabelOutcome: outcome}, 1)
+		} else {
+			o.metrics.AddCounter(MetricRuns, map[string]string{MetricLabelOutcome: outcomeOf(ev)}, 1)
+		}
+		o.metrics.Observe(MetricDataConnsPerHub, nil, float64(o.dataConnCount))
+	case PhaseCloseDataSrc:
+	default:
+		if ev.Phase == PhaseGetDataConn && ev.Err.IsOk() {
+			o.dataConnCount++
+		}
+		if ev.Phase == PhaseOnTxnFailure && ev.Report != nil {
+			o.metrics.AddCounter(MetricTxnFailures, map[string]string{
+				MetricLabelDataConnType: ev.DataConnType,
+				MetricLabelCauseState:   ev.Report.Cause.State.String(),
+				MetricLabelRecovery:     ev.Report.RecoveryForCommit().String(),
+			}, 1)
+		}
+		o.metrics.Observe(MetricPhaseSeconds, map[string]string{
+			MetricLabelPhase:        ev.Phase.String(),
+			MetricLabelDataConnType: ev.DataConnType,
+			MetricLabelOutcome:      outcomeOf(ev),
+		}, ev.Duration.Seconds())
+	}
+}
+
+// DefaultHistogramBuckets is the default upper bounds of the buckets of histograms of an
+// ExpvarMetricsSink. They cover both durations in seconds and small counts.
+var DefaultHistogramBuckets = []float64{
+	0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 20, 50,
+}
+
+// ExpvarMetricsSink is a MetricsSink which publishes metrics as an expvar.Map, so that they are
+// served at /debug/vars along with the other expvar variables.
+//
+// The keys of the map are metric names followed by labels in the form of
+// `name{key1="value1",key2="value2"}` with labels sorted by key. Counters are expvar.Int, and
+// histograms are JSON objects holding "count", "sum" and cumulative "buckets".
+type ExpvarMetricsSink struct {
+	vars    *expvar.Map
+	buckets []float64
+	mutex   sync.Mutex
+}
+
+// NewExpvarMetricsSink creates a new ExpvarMetricsSink and publishes its map under the name.
+// If buckets is nil, DefaultHistogramBuckets is used.
+// Like expvar.Publish, this function panics if the name is already published.
+func NewExpvarMetricsSink(name string, buckets []float64) *ExpvarMetricsSink {
+	if buckets == nil {
+		buckets = DefaultHistogramBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &ExpvarMetricsSink{vars: expvar.NewMap(name), buckets: buckets}
+}
+
+// Map returns the expvar.Map which holds the metrics.
+func (sink *ExpvarMetricsSink) Map() *expvar.Map {
+	return sink.vars
+}
+
+// AddCounter adds delta to the counter identified by the name and labels.
+func (sink *ExpvarMetricsSink) AddCounter(name string, labels map[string]string, delta int64) {
+	sink.vars.Add(metricKey(name, labels), delta)
+}
+
+// Observe records the value to the histogram identified by the name and labels.
+func (sink *ExpvarMetricsSink) Observe(name string, labels map[string]string, value float64) {
+	key := metricKey(name, labels)
+
+	sink.mutex.Lock()
+	h, ok := sink.vars.Get(key).(*expvarHistogram)
+	if !ok {
+		h = &expvarHistogram{bounds: sink.buckets, counts: make([]int64, len(sink.buckets))}
+		sink.vars.Set(key, h)
+	}
+	sink.mutex.Unlock()
+
+	h.observe(value)
+}
+
+func metricKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+type expvarHistogram struct {
+	mutex  sync.Mutex
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (h *expvarHistogram) observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.count++
+	h.sum += value
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// String returns the JSON representation of this histogram, which implements expvar.Var.
+func (h *expvarHistogram) String() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var b strings.Builder
+	b.WriteString(`{"count":`)
+	b.WriteString(strconv.FormatInt(h.count, 10))
+	b.WriteString(`,"sum":`)
+	b.WriteString(strconv.FormatFloat(h.sum, 'g', -1, 64))
+	b.WriteString(`,"buckets":{`)
+	for i, bound := range h.bounds {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		b.WriteString(strconv.FormatFloat(bound, 'g', -1, 64))
+		b.WriteString(`":`)
+		b.WriteString(strconv.FormatInt(h.counts[i], 10))
+	}
+	b.WriteString(`}}`)
+	return b.String()
+}
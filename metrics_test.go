@@ -0,0 +1,185 @@
+package sabi
+
+import (
+	"container/list"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type memMetricsSink struct {
+	mutex    sync.Mutex
+	counters map[string]int64
+	observed map[string][]float64
+}
+
+func newMemMetricsSink() *memMetricsSink {
+	return &memMetricsSink{
+		counters: make(map[string]int64),
+		observed: make(map[string][]float64),
+	}
+}
+
+func (sink *memMetricsSink) AddCounter(name string, labels map[string]string, delta int64) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.counters[metricKey(name, labels)] += delta
+}
+
+func (sink *memMetricsSink) Observe(name string, labels map[string]string, value float64) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	key := metricKey(name, labels)
+	sink.observed[key] = append(sink.observed[key], value)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("metricKey", func(t *testing.T) {
+		assert.Equal(t, metricKey("m", nil), "m")
+		assert.Equal(t, metricKey("m", map[string]string{"b": "x", "a": `"y"`}),
+			`m{a="\"y\"",b="x"}`)
+	})
+
+	t.Run("global data srcs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		sink := newMemMetricsSink()
+		SetMetricsSink(sink)
+
+		logger := list.New()
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		Uses("bar", NewMyDataSrc(2, Failure_Setup, logger))
+		assert.True(t, Setup().IsNotOk())
+
+		assert.Len(t, sink.observed, 2)
+		assert.Len(t, sink.observed[`sabi_data_src_setup_seconds{`+
+			`data_src_type="*sabi.MyDataSrc",outcome="ok"}`], 1)
+		assert.Len(t, sink.observed[`sabi_data_src_setup_seconds{`+
+			`data_src_type="*sabi.MyDataSrc",outcome="error"}`], 1)
+		assert.Len(t, sink.counters, 0)
+	})
+
+	t.Run("run and txn", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		sink := newMemMetricsSink()
+		SetMetricsSink(sink)
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		hub.Uses("bar", NewMyDataSrc(2, Failure_None, logger))
+
+		getBoth := func(data any) errs.Err {
+			if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+				return err
+			}
+			_, err := GetDataConn[*MyDataConn](data, "bar")
+			return err
+		}
+
+		assert.True(t, Txn(hub, getBoth).IsOk())
+		assert.True(t, Txn(hub, func(data any) errs.Err {
+			if err := getBoth(data); err.IsNotOk() {
+				return err
+			}
+			return errs.New("logic error")
+		}).IsNotOk())
+		assert.True(t, Run(hub, func(data any) errs.Err { return errs.Ok() }).IsOk())
+		assert.True(t, Run(hub, func(data any) errs.Err { return errs.New("x") }).IsNotOk())
+
+		assert.Equal(t, sink.counters, map[string]int64{
+			`sabi_txns_total{outcome="committed"}`:   1,
+			`sabi_txns_total{outcome="rolled_back"}`: 1,
+			`sabi_runs_total{outcome="ok"}`:          1,
+			`sabi_runs_total{outcome="error"}`:       1,
+			`sabi_txn_failures_total{cause_state="NoneByUncommitted",` +
+				`data_conn_type="*sabi.MyDataConn",recovery="RerunLogicAndCommit"}`: 2,
+		})
+		assert.Equal(t, sink.observed["sabi_data_conns_per_hub"], []float64{2, 2, 0, 0})
+		assert.Len(t, sink.observed[`sabi_data_src_setup_seconds{`+
+			`data_src_type="*sabi.MyDataSrc",outcome="ok"}`], 2)
+
+		assert.Len(t, sink.observed[`sabi_phase_seconds{data_conn_type="*sabi.MyDataConn",`+
+			`outcome="ok",phase="GetDataConn"}`], 4)
+		for _, phase := range []string{"PreCommit", "Commit", "PostCommit", "Rollback", "OnTxnFailure"} {
+			assert.Len(t, sink.observed[`sabi_phase_seconds{data_conn_type="*sabi.MyDataConn",`+
+				`outcome="ok",phase="`+phase+`"}`], 2, phase)
+		}
+	})
+
+	t.Run("txn but failed to commit or post commit", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		sink := newMemMetricsSink()
+		SetMetricsSink(sink)
+
+		logger := list.New()
+		for _, failure := range []Failure{Failure_Commit, Failure_PostCommit} {
+			func() {
+				hub := NewDataHub()
+				defer hub.Close()
+				hub.Uses("foo", NewMyDataSrc(1, failure, logger))
+
+				assert.True(t, Txn(hub, func(data any) errs.Err {
+					_, err := GetDataConn[*MyDataConn](data, "foo")
+					return err
+				}).IsNotOk())
+			}()
+		}
+
+		assert.Equal(t, sink.counters, map[string]int64{
+			`sabi_txns_total{outcome="rolled_back"}`:        1,
+			`sabi_txns_total{outcome="post_commit_failed"}`: 1,
+			`sabi_txn_failures_total{cause_state="CommitFailure",` +
+				`data_conn_type="*sabi.MyDataConn",recovery="ResolveCauseThenRerunLogicAndCommit"}`: 1,
+			`sabi_txn_failures_total{cause_state="PostCommitFailure",` +
+				`data_conn_type="*sabi.MyDataConn",recovery="ResolveCauseThenRerunPostCommit"}`: 1,
+		})
+		assert.Len(t, sink.observed[`sabi_phase_seconds{data_conn_type="*sabi.MyDataConn",`+
+			`outcome="error",phase="Commit"}`], 1)
+	})
+}
+
+var expvarNameSeq atomic.Int64
+
+// uniqueExpvarName returns a name not published yet, because expvar.Publish panics on a name
+// published by a previous run of the test, such as with `go test -count=2`.
+func uniqueExpvarName(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, expvarNameSeq.Add(1))
+}
+
+func TestExpvarMetricsSink(t *testing.T) {
+	name := uniqueExpvarName("sabi_test_metrics")
+	sink := NewExpvarMetricsSink(name, []float64{1, 0.1})
+	assert.Equal(t, sink.buckets, []float64{0.1, 1})
+	assert.Same(t, expvar.Get(name), sink.Map())
+
+	sink.AddCounter("c", map[string]string{"k": "v"}, 2)
+	sink.AddCounter("c", map[string]string{"k": "v"}, 3)
+	sink.Observe("h", nil, 0.05)
+	sink.Observe("h", nil, 0.5)
+	sink.Observe("h", nil, 5)
+
+	m := make(map[string]any)
+	assert.Nil(t, json.Unmarshal([]byte(sink.Map().String()), &m))
+	assert.Equal(t, m[`c{k="v"}`], float64(5))
+	assert.Equal(t, m["h"], map[string]any{
+		"count":   float64(3),
+		"sum":     5.55,
+		"buckets": map[string]any{"0.1": float64(1), "1": float64(2)},
+	})
+
+	assert.Equal(t, len(NewExpvarMetricsSink(uniqueExpvarName("sabi_test_metrics"), nil).buckets),
+		len(DefaultHistogramBuckets))
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+// Namespace is a prefix for names of data sources, which prevents names registered by
+// different libraries from colliding in the flat name space of Uses.
+//
+// A name in a namespace is joined with a slash, for example "billing/db" for the name "db" in
+// the namespace "billing".
+type Namespace string
+
+// Name returns the given name qualified with this namespace.
+func (ns Namespace) Name(name string) string {
+	return string(ns) + "/" + name
+}
+
+// Uses registers a global data source with the given name qualified with this namespace.
+func (ns Namespace) Uses(name string, ds DataSrc) {
+	Uses(ns.Name(name), ds)
+}
+
+// UsesIn registers a local data source to the given DataHub with the given name qualified with
+// this namespace.
+func (ns Namespace) UsesIn(hub DataHub, name string, ds DataSrc) {
+	hub.Uses(ns.Name(name), ds)
+}
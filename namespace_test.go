@@ -0,0 +1,47 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func TestNamespace(t *testing.T) {
+	t.Run("Name", func(t *testing.T) {
+		ns := Namespace("billing")
+		assert.Equal(t, ns.Name("db"), "billing/db")
+	})
+
+	t.Run("Uses", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Namespace("billing").Uses("db", NewMyDataSrc(1, Failure_None, logger))
+		assert.Len(t, globalDataSrcManager.listUnready, 1)
+		assert.Equal(t, globalDataSrcManager.listUnready[0].name, "billing/db")
+	})
+
+	t.Run("UsesIn and Alias", func(t *testing.T) {
+		logger := list.New()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		Namespace("billing").UsesIn(hub, "db", NewMyDataSrc(1, Failure_None, logger))
+		hub.Alias("db", "billing/db")
+
+		err := Run(hub, func(data any) errs.Err {
+			dc1, err := GetDataConn[*MyDataConn](data, "db")
+			assert.True(t, err.IsOk())
+			dc2, err := GetDataConn[*MyDataConn](data, "billing/db")
+			assert.True(t, err.IsOk())
+			assert.Same(t, dc1, dc2)
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	})
+}
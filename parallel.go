@@ -0,0 +1,46 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"sort"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// FailToRunInParallel represents an error reason indicating that one or more functions run by
+	// Parallel returned errors. It wraps the errors in the order of the functions, and the Index of
+	// each entry is the index of the function in the arguments of Parallel.
+	FailToRunInParallel struct {
+		Errors []ErrEntry
+	}
+)
+
+// Parallel runs the specified functions concurrently in separate goroutines and waits for all of
+// them to finish. It is intended for logic which fans out data accesses through the same DataHub
+// within Run or Txn.
+//
+// The DataHub passed to the logic can be shared by these goroutines: the creation of a DataConn
+// is serialized per name so that only one DataConn is created for each name, and the DataConns
+// created in the goroutines are committed or rolled back together when the Txn ends.
+// Note that the methods of a DataConn itself are not made safe for concurrent use.
+//
+// If any function returns an error, this function returns an error with the reason
+// FailToRunInParallel after all functions finish.
+func Parallel(fns ...func() errs.Err) errs.Err {
+	ag := AsyncGroup{}
+	for i, fn := range fns {
+		ag._index = i
+		ag.Add(fn)
+	}
+
+	errors := ag.join()
+	if len(errors) > 0 {
+		sort.Slice(errors, func(i, j int) bool { return errors[i].Index < errors[j].Index })
+		return errs.New(FailToRunInParallel{Errors: errors})
+	}
+	return errs.Ok()
+}
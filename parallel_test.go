@@ -0,0 +1,158 @@
+package sabi
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type SharedDataSrc struct {
+	creates atomic.Int32
+	commits atomic.Int32
+	closes  atomic.Int32
+}
+
+func (ds *SharedDataSrc) Setup(ag *AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *SharedDataSrc) Close()                        {}
+func (ds *SharedDataSrc) CreateDataConn() (DataConn, errs.Err) {
+	ds.creates.Add(1)
+	// Widens the window in which other goroutines request the same DataConn.
+	time.Sleep(time.Millisecond)
+	return &SharedDataConn{ds: ds}, errs.Ok()
+}
+func (ds *SharedDataSrc) DataConnType() reflect.Type { return reflect.TypeFor[*SharedDataConn]() }
+
+type SharedDataConn struct {
+	ds *SharedDataSrc
+}
+
+func (dc *SharedDataConn) IsCommitted() bool                 { return false }
+func (dc *SharedDataConn) PreCommit(ag *AsyncGroup) errs.Err { return errs.Ok() }
+func (dc *SharedDataConn) Commit(ag *AsyncGroup) errs.Err {
+	dc.ds.commits.Add(1)
+	return errs.Ok()
+}
+func (dc *SharedDataConn) PostCommit(ag *AsyncGroup) errs.Err                      { return errs.Ok() }
+func (dc *SharedDataConn) Rollback(ag *AsyncGroup) errs.Err                        { return errs.Ok() }
+func (dc *SharedDataConn) OnTxnFailure(ag *AsyncGroup, reports []TxnFailureReport) {}
+func (dc *SharedDataConn) Close()                                                  { dc.ds.closes.Add(1) }
+
+type SharedDataConnType interface {
+	sharedDataConn()
+}
+
+func (dc *SharedDataConn) sharedDataConn() {}
+
+func TestParallel(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var n atomic.Int32
+		err := Parallel(
+			func() errs.Err { n.Add(1); return errs.Ok() },
+			func() errs.Err { n.Add(2); return errs.Ok() },
+		)
+		assert.True(t, err.IsOk())
+		assert.Equal(t, n.Load(), int32(3))
+	})
+
+	t.Run("no function", func(t *testing.T) {
+		err := Parallel()
+		assert.True(t, err.IsOk())
+	})
+
+	t.Run("errors in the order of functions", func(t *testing.T) {
+		err := Parallel(
+			func() errs.Err { return errs.Ok() },
+			func() errs.Err { time.Sleep(10 * time.Millisecond); return errs.New("err1") },
+			func() errs.Err { return errs.New("err2") },
+		)
+		switch r := err.Reason().(type) {
+		case FailToRunInParallel:
+			assert.Len(t, r.Errors, 2)
+			assert.Equal(t, r.Errors[0].Index, 1)
+			assert.Equal(t, r.Errors[0].Err.Reason(), "err1")
+			assert.Equal(t, r.Errors[1].Index, 2)
+			assert.Equal(t, r.Errors[1].Err.Reason(), "err2")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("share a DataHub in Txn", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		foo := &SharedDataSrc{}
+		bar := &SharedDataSrc{}
+
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", foo)
+		hub.Uses("bar", bar)
+
+		var events atomic.Int32
+		hub.AddListener(ListenerFunc(func(ev Event) {
+			if ev.Phase == PhaseGetDataConn {
+				events.Add(1)
+			}
+		}))
+
+		err := Txn(hub, func(data any) errs.Err {
+			fns := make([]func() errs.Err, 20)
+			for i := range fns {
+				fns[i] = func() errs.Err {
+					if _, err := GetDataConn[*SharedDataConn](data, "foo"); err.IsNotOk() {
+						return err
+					}
+					_, err := GetDataConn[*SharedDataConn](data, "bar")
+					return err
+				}
+			}
+			return Parallel(fns...)
+		})
+		assert.True(t, err.IsOk())
+
+		assert.Equal(t, foo.creates.Load(), int32(1))
+		assert.Equal(t, foo.commits.Load(), int32(1))
+		assert.Equal(t, foo.closes.Load(), int32(1))
+		assert.Equal(t, bar.creates.Load(), int32(1))
+		assert.Equal(t, bar.commits.Load(), int32(1))
+		assert.Equal(t, bar.closes.Load(), int32(1))
+		assert.Equal(t, events.Load(), int32(2))
+	})
+
+	t.Run("GetDataConnByType concurrently with GetDataConn", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		foo := &SharedDataSrc{}
+
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", foo)
+		hub.Uses("bar", &CountingDataSrc{})
+
+		err := Txn(hub, func(data any) errs.Err {
+			fns := make([]func() errs.Err, 20)
+			for i := range fns {
+				fns[i] = func() errs.Err {
+					if i%2 == 0 {
+						_, err := GetDataConnByType[SharedDataConnType](data)
+						return err
+					}
+					_, err := GetDataConn[*SharedDataConn](data, "foo")
+					return err
+				}
+			}
+			return Parallel(fns...)
+		})
+		assert.True(t, err.IsOk())
+
+		assert.Equal(t, foo.creates.Load(), int32(1))
+		assert.Equal(t, foo.commits.Load(), int32(1))
+		assert.Equal(t, foo.closes.Load(), int32(1))
+	})
+}
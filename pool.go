@@ -0,0 +1,443 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// PoolIsClosed represents an error reason indicating that a resource was requested from a
+	// Pool which has already been closed.
+	PoolIsClosed struct{}
+
+	// PoolWaitTimeout represents an error reason indicating that no resource of a Pool became
+	// available within the configured wait timeout because the pool had reached its maximum
+	// number of open resources.
+	PoolWaitTimeout struct {
+		Timeout time.Duration
+	}
+
+	// FailToOpenPooledResource represents an error reason indicating that the Open function of
+	// a Pool failed to open a new resource.
+	FailToOpenPooledResource struct{}
+)
+
+// PoolConfig is the configuration of a Pool.
+//
+// Open is required, and the other fields are optional.
+// A zero value of a limit field means that the corresponding limit is not applied.
+type PoolConfig[R any] struct {
+	// MaxOpen is the maximum number of resources which are open at the same time, including
+	// both idle and in-use resources.
+	MaxOpen int
+
+	// MaxIdle is the maximum number of idle resources kept in the pool.
+	// Resources returned to a pool which already has this number of idle resources are closed.
+	MaxIdle int
+
+	// MaxLifetime is the maximum amount of time a resource may be reused since it was opened.
+	MaxLifetime time.Duration
+
+	// WaitTimeout is the maximum amount of time to wait for a resource when the pool has
+	// reached MaxOpen.
+	WaitTimeout time.Duration
+
+	// Open opens a new resource.
+	Open func() (R, errs.Err)
+
+	// Close closes a resource. It is called when a resource is removed from the pool.
+	Close func(R)
+
+	// Validate checks the health of an idle resource when it is checked out.
+	// A resource for which this function returns an error is closed and another resource is
+	// tried.
+	Validate func(R) errs.Err
+}
+
+// PoolStats is a snapshot of the statistics of a Pool, useful for monitoring.
+type PoolStats struct {
+	// MaxOpen is the configured maximum number of open resources.
+	MaxOpen int
+	// Open is the number of open resources, both idle and in use.
+	Open int
+	// InUse is the number of resources currently checked out.
+	InUse int
+	// Idle is the number of idle resources.
+	Idle int
+
+	// WaitCount is the total number of checkouts which had to wait for a resource.
+	WaitCount int64
+	// WaitDuration is the total time spent waiting for resources.
+	WaitDuration time.Duration
+	// WaitTimeoutCount is the total number of checkouts which failed by the wait timeout.
+	WaitTimeoutCount int64
+
+	// MaxIdleClosed is the total number of resources closed because of MaxIdle.
+	MaxIdleClosed int64
+	// MaxLifetimeClosed is the total number of resources closed because of MaxLifetime.
+	MaxLifetimeClosed int64
+	// InvalidClosed is the total number of resources closed because their validation failed
+	// or they were discarded.
+	InvalidClosed int64
+}
+
+// Pool is a bounded pool of reusable resources, such as network connections or sessions,
+// which are shared by DataConn instances created by a DataSrc.
+//
+// A resource is checked out with Get, and must be returned with Release or Discard of the
+// returned Pooled value. When the pool has reached its maximum number of open resources, Get
+// waits until another resource is returned or the wait timeout elapses.
+type Pool[R any] struct {
+	cfg     PoolConfig[R]
+	mutex   sync.Mutex
+	idle    []*Pooled[R]
+	waiters []chan struct{}
+	numOpen int
+	closed  bool
+	stats   PoolStats
+}
+
+// Pooled is a resource checked out from a Pool.
+type Pooled[R any] struct {
+	pool     *Pool[R]
+	res      R
+	openedAt time.Time
+	returned bool
+}
+
+// NewPool creates a new Pool with the given configuration.
+func NewPool[R any](cfg PoolConfig[R]) *Pool[R] {
+	return &Pool[R]{
+		cfg:     cfg,
+		idle:    make([]*Pooled[R], 0),
+		waiters: make([]chan struct{}, 0),
+	}
+}
+
+// Get checks out a resource from this pool.
+//
+// It reuses a validated idle resource if there is one, opens a new resource if the number of
+// open resources is less than MaxOpen, or otherwise waits for a returned resource.
+// It returns an error with the reason PoolIsClosed, PoolWaitTimeout or
+// FailToOpenPooledResource if no resource can be provided.
+func (pool *Pool[R]) Get() (*Pooled[R], errs.Err) {
+	var deadline time.Time
+	waited := false
+	startWait := time.Time{}
+
+	pool.mutex.Lock()
+	for {
+		if pool.closed {
+			pool.mutex.Unlock()
+			return nil, errs.New(PoolIsClosed{})
+		}
+
+		if n := len(pool.idle); n > 0 {
+			p := pool.idle[n-1]
+			pool.idle = pool.idle[:n-1]
+			pool.stats.InUse++
+
+			if pool.isExpired(p) {
+				pool.stats.MaxLifetimeClosed++
+				pool.discardLocked(p)
+				pool.mutex.Lock()
+				continue
+			}
+			pool.mutex.Unlock()
+
+			if pool.cfg.Validate != nil {
+				if err := pool.cfg.Validate(p.res); err.IsNotOk() {
+					pool.mutex.Lock()
+					pool.stats.InvalidClosed++
+					pool.discardLocked(p)
+					pool.mutex.Lock()
+					continue
+				}
+			}
+			p.returned = false
+			pool.recordWait(waited, startWait)
+			return p, errs.Ok()
+		}
+
+		if pool.cfg.MaxOpen <= 0 || pool.numOpen < pool.cfg.MaxOpen {
+			pool.numOpen++
+			pool.stats.InUse++
+			pool.mutex.Unlock()
+
+			res, err := pool.cfg.Open()
+			if err.IsNotOk() {
+				pool.mutex.Lock()
+				pool.numOpen--
+				pool.stats.InUse--
+				pool.notifyLocked()
+				pool.mutex.Unlock()
+				return nil, errs.New(FailToOpenPooledResource{}, err)
+			}
+			pool.recordWait(waited, startWait)
+			return &Pooled[R]{pool: pool, res: res, openedAt: time.Now()}, errs.Ok()
+		}
+
+		if !waited {
+			waited = true
+			startWait = time.Now()
+			if pool.cfg.WaitTimeout > 0 {
+				deadline = startWait.Add(pool.cfg.WaitTimeout)
+			}
+		}
+
+		ch := make(chan struct{}, 1)
+		pool.waiters = append(pool.waiters, ch)
+		pool.mutex.Unlock()
+
+		if deadline.IsZero() {
+			<-ch
+		} else {
+			timer := time.NewTimer(time.Until(deadline))
+			select {
+			case <-ch:
+				timer.Stop()
+			case <-timer.C:
+				pool.mutex.Lock()
+				pool.removeWaiterLocked(ch)
+				pool.stats.WaitCount++
+				pool.stats.WaitDuration += time.Since(startWait)
+				pool.stats.WaitTimeoutCount++
+				pool.mutex.Unlock()
+				return nil, errs.New(PoolWaitTimeout{Timeout: pool.cfg.WaitTimeout})
+			}
+		}
+
+		pool.mutex.Lock()
+	}
+}
+
+// Stats returns the current statistics of this pool.
+func (pool *Pool[R]) Stats() PoolStats {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	stats := pool.stats
+	stats.MaxOpen = pool.cfg.MaxOpen
+	stats.Open = pool.numOpen
+	stats.Idle = len(pool.idle)
+	return stats
+}
+
+// Close closes this pool and its idle resources.
+// Resources in use are closed when they are returned, and goroutines waiting in Get fail with
+// the reason PoolIsClosed.
+func (pool *Pool[R]) Close() {
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return
+	}
+	pool.closed = true
+	idle := pool.idle
+	pool.idle = nil
+	pool.numOpen -= len(idle)
+	for _, ch := range pool.waiters {
+		ch <- struct{}{}
+	}
+	pool.waiters = nil
+	pool.mutex.Unlock()
+
+	for _, p := range idle {
+		pool.closeResource(p.res)
+	}
+}
+
+// Resource returns the checked out resource.
+func (p *Pooled[R]) Resource() R {
+	return p.res
+}
+
+// Release returns the resource to the pool so that it can be reused.
+// The resource is closed instead if the pool is closed, the resource has exceeded its
+// MaxLifetime, or the pool already has MaxIdle idle resources.
+// Calling this method more than once has no effect.
+func (p *Pooled[R]) Release() {
+	pool := p.pool
+	pool.mutex.Lock()
+	if p.returned {
+		pool.mutex.Unlock()
+		return
+	}
+	p.returned = true
+
+	switch {
+	case pool.closed:
+		pool.discardLocked(p)
+	case pool.isExpired(p):
+		pool.stats.MaxLifetimeClosed++
+		pool.discardLocked(p)
+	case pool.cfg.MaxIdle > 0 && len(pool.idle) >= pool.cfg.MaxIdle:
+		pool.stats.MaxIdleClosed++
+		pool.discardLocked(p)
+	default:
+		pool.idle = append(pool.idle, p)
+		pool.stats.InUse--
+		pool.notifyLocked()
+		pool.mutex.Unlock()
+	}
+}
+
+// Discard closes the resource instead of returning it to the pool.
+// This should be called when the resource is known to be broken.
+// Calling this method after Release or Discard has no effect.
+func (p *Pooled[R]) Discard() {
+	pool := p.pool
+	pool.mutex.Lock()
+	if p.returned {
+		pool.mutex.Unlock()
+		return
+	}
+	p.returned = true
+	pool.stats.InvalidClosed++
+	pool.discardLocked(p)
+}
+
+func (pool *Pool[R]) isExpired(p *Pooled[R]) bool {
+	return pool.cfg.MaxLifetime > 0 && time.Since(p.openedAt) >= pool.cfg.MaxLifetime
+}
+
+// discardLocked closes the resource of an in-use entry. It must be called with the mutex
+// locked, and unlocks it.
+func (pool *Pool[R]) discardLocked(p *Pooled[R]) {
+	pool.numOpen--
+	pool.stats.InUse--
+	pool.notifyLocked()
+	pool.mutex.Unlock()
+
+	pool.closeResource(p.res)
+}
+
+func (pool *Pool[R]) closeResource(res R) {
+	if pool.cfg.Close != nil {
+		pool.cfg.Close(res)
+	}
+}
+
+func (pool *Pool[R]) notifyLocked() {
+	if len(pool.waiters) > 0 {
+		ch := pool.waiters[0]
+		pool.waiters = pool.waiters[1:]
+		ch <- struct{}{}
+	}
+}
+
+func (pool *Pool[R]) removeWaiterLocked(ch chan struct{}) {
+	for i := range pool.waiters {
+		if pool.waiters[i] == ch {
+			pool.waiters = append(pool.waiters[:i], pool.waiters[i+1:]...)
+			return
+		}
+	}
+	// The notification was sent at the same time as the timeout, so it is passed to the next
+	// waiter.
+	select {
+	case <-ch:
+		pool.notifyLocked()
+	default:
+	}
+}
+
+func (pool *Pool[R]) recordWait(waited bool, start time.Time) {
+	if waited {
+		pool.mutex.Lock()
+		pool.stats.WaitCount++
+		pool.stats.WaitDuration += time.Since(start)
+		pool.mutex.Unlock()
+	}
+}
+
+// PooledDataSrc is a DataSrc which manages a Pool of underlying resources and creates DataConn
+// instances each of which holds a resource checked out from the pool.
+//
+// DataSrc implementations can embed a pointer to this struct to get pooling.
+// The DataConn created by the newDataConn function must call Release (or Discard) of the given
+// Pooled value in its Close method.
+type PooledDataSrc[R any] struct {
+	cfg          PoolConfig[R]
+	newDataConn  func(*Pooled[R]) (DataConn, errs.Err)
+	dataConnType reflect.Type
+	pool         *Pool[R]
+}
+
+// NewPooledDataSrc creates a new PooledDataSrc with the given pool configuration and the
+// function to create a DataConn of type C from a checked out resource.
+//
+// The created data source declares C as the type of its DataConns with DataConnTypeDeclarer,
+// unless C is an interface type.
+func NewPooledDataSrc[R any, C DataConn](
+	cfg PoolConfig[R], newDataConn func(*Pooled[R]) (C, errs.Err),
+) *PooledDataSrc[R] {
+	var dataConnType reflect.Type
+	if t := reflect.TypeFor[C](); t.Kind() != reflect.Interface {
+		dataConnType = t
+	}
+	return &PooledDataSrc[R]{
+		cfg: cfg,
+		newDataConn: func(p *Pooled[R]) (DataConn, errs.Err) {
+			dc, err := newDataConn(p)
+			if err.IsNotOk() {
+				return nil, err
+			}
+			return dc, err
+		},
+		dataConnType: dataConnType,
+	}
+}
+
+// Setup creates the pool of this data source.
+func (ds *PooledDataSrc[R]) Setup(ag *AsyncGroup) errs.Err {
+	ds.pool = NewPool(ds.cfg)
+	return errs.Ok()
+}
+
+// Close closes the pool of this data source.
+func (ds *PooledDataSrc[R]) Close() {
+	if ds.pool != nil {
+		ds.pool.Close()
+	}
+}
+
+// CreateDataConn checks out a resource from the pool and creates a DataConn holding it.
+// If the DataConn cannot be created, the resource is returned to the pool.
+func (ds *PooledDataSrc[R]) CreateDataConn() (DataConn, errs.Err) {
+	if ds.pool == nil {
+		return nil, errs.New(PoolIsClosed{})
+	}
+
+	p, err := ds.pool.Get()
+	if err.IsNotOk() {
+		return nil, err
+	}
+
+	dc, err := ds.newDataConn(p)
+	if err.IsNotOk() || dc == nil {
+		p.Release()
+	}
+	return dc, err
+}
+
+// DataConnType returns the type of the DataConns created by this data source, or nil if it is
+// not known because the function passed to NewPooledDataSrc returns an interface type.
+func (ds *PooledDataSrc[R]) DataConnType() reflect.Type {
+	return ds.dataConnType
+}
+
+// Stats returns the current statistics of the pool of this data source.
+func (ds *PooledDataSrc[R]) Stats() PoolStats {
+	if ds.pool == nil {
+		return PoolStats{MaxOpen: ds.cfg.MaxOpen}
+	}
+	return ds.pool.Stats()
+}
@@ -0,0 +1,386 @@
+package sabi
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type poolRes struct {
+	id    int
+	valid bool
+}
+
+type poolLogger struct {
+	mutex  sync.Mutex
+	logger *list.List
+}
+
+func (l *poolLogger) push(s string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.logger.PushBack(s)
+}
+
+func newTestPoolConfig(l *poolLogger) PoolConfig[*poolRes] {
+	n := 0
+	return PoolConfig[*poolRes]{
+		Open: func() (*poolRes, errs.Err) {
+			l.mutex.Lock()
+			n++
+			id := n
+			l.mutex.Unlock()
+			l.push(fmt.Sprintf("open %d", id))
+			return &poolRes{id: id, valid: true}, errs.Ok()
+		},
+		Close: func(r *poolRes) {
+			l.push(fmt.Sprintf("close %d", r.id))
+		},
+	}
+}
+
+type PoolDataConn struct {
+	p *Pooled[*poolRes]
+}
+
+func (dc *PoolDataConn) Commit(ag *AsyncGroup) errs.Err                          { return errs.Ok() }
+func (dc *PoolDataConn) PreCommit(ag *AsyncGroup) errs.Err                       { return errs.Ok() }
+func (dc *PoolDataConn) PostCommit(ag *AsyncGroup) errs.Err                      { return errs.Ok() }
+func (dc *PoolDataConn) IsCommitted() bool                                       { return false }
+func (dc *PoolDataConn) Rollback(ag *AsyncGroup) errs.Err                        { return errs.Ok() }
+func (dc *PoolDataConn) OnTxnFailure(ag *AsyncGroup, reports []TxnFailureReport) {}
+func (dc *PoolDataConn) Close()                                                  { dc.p.Release() }
+
+func TestPool(t *testing.T) {
+	t.Run("get and release", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		pool := NewPool(newTestPoolConfig(l))
+
+		p1, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p1.Resource().id, 1)
+		p2, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p2.Resource().id, 2)
+
+		stats := pool.Stats()
+		assert.Equal(t, stats.Open, 2)
+		assert.Equal(t, stats.InUse, 2)
+		assert.Equal(t, stats.Idle, 0)
+
+		p1.Release()
+		p1.Release()
+
+		stats = pool.Stats()
+		assert.Equal(t, stats.Open, 2)
+		assert.Equal(t, stats.InUse, 1)
+		assert.Equal(t, stats.Idle, 1)
+
+		p3, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p3.Resource().id, 1)
+
+		p2.Discard()
+		p3.Release()
+		pool.Close()
+
+		stats = pool.Stats()
+		assert.Equal(t, stats.Open, 0)
+		assert.Equal(t, stats.InUse, 0)
+		assert.Equal(t, stats.Idle, 0)
+		assert.Equal(t, stats.InvalidClosed, int64(1))
+
+		_, err = pool.Get()
+		switch err.Reason().(type) {
+		case PoolIsClosed:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		log := l.logger.Front()
+		assert.Equal(t, log.Value, "open 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "open 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("max idle", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxIdle = 1
+		pool := NewPool(cfg)
+		defer pool.Close()
+
+		p1, _ := pool.Get()
+		p2, _ := pool.Get()
+		p1.Release()
+		p2.Release()
+
+		stats := pool.Stats()
+		assert.Equal(t, stats.Open, 1)
+		assert.Equal(t, stats.Idle, 1)
+		assert.Equal(t, stats.MaxIdleClosed, int64(1))
+	})
+
+	t.Run("max lifetime", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxLifetime = 10 * time.Millisecond
+		pool := NewPool(cfg)
+		defer pool.Close()
+
+		p1, _ := pool.Get()
+		p1.Release()
+		time.Sleep(20 * time.Millisecond)
+
+		p2, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p2.Resource().id, 2)
+
+		time.Sleep(20 * time.Millisecond)
+		p2.Release()
+
+		stats := pool.Stats()
+		assert.Equal(t, stats.Open, 0)
+		assert.Equal(t, stats.MaxLifetimeClosed, int64(2))
+	})
+
+	t.Run("validate on checkout", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.Validate = func(r *poolRes) errs.Err {
+			if !r.valid {
+				return errs.New("invalid")
+			}
+			return errs.Ok()
+		}
+		pool := NewPool(cfg)
+		defer pool.Close()
+
+		p1, _ := pool.Get()
+		p1.Resource().valid = false
+		p1.Release()
+
+		p2, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p2.Resource().id, 2)
+		p2.Release()
+
+		stats := pool.Stats()
+		assert.Equal(t, stats.Open, 1)
+		assert.Equal(t, stats.InvalidClosed, int64(1))
+	})
+
+	t.Run("fail to open", func(t *testing.T) {
+		pool := NewPool(PoolConfig[int]{
+			MaxOpen: 1,
+			Open:    func() (int, errs.Err) { return 0, errs.New("open error") },
+		})
+		defer pool.Close()
+
+		_, err := pool.Get()
+		switch err.Reason().(type) {
+		case FailToOpenPooledResource:
+			assert.Equal(t, err.Cause().(errs.Err).Reason(), "open error")
+		default:
+			assert.Fail(t, err.Error())
+		}
+		assert.Equal(t, pool.Stats().Open, 0)
+	})
+
+	t.Run("wait and timeout", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxOpen = 1
+		cfg.WaitTimeout = 20 * time.Millisecond
+		pool := NewPool(cfg)
+		defer pool.Close()
+
+		p1, _ := pool.Get()
+
+		_, err := pool.Get()
+		switch rsn := err.Reason().(type) {
+		case PoolWaitTimeout:
+			assert.Equal(t, rsn.Timeout, 20*time.Millisecond)
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			p1.Release()
+		}()
+
+		p2, err := pool.Get()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, p2.Resource().id, 1)
+		p2.Release()
+
+		stats := pool.Stats()
+		assert.Equal(t, stats.MaxOpen, 1)
+		assert.Equal(t, stats.WaitCount, int64(2))
+		assert.Equal(t, stats.WaitTimeoutCount, int64(1))
+		assert.True(t, stats.WaitDuration > 0)
+	})
+
+	t.Run("close while waiting", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxOpen = 1
+		pool := NewPool(cfg)
+
+		p1, _ := pool.Get()
+
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			pool.Close()
+		}()
+
+		_, err := pool.Get()
+		switch err.Reason().(type) {
+		case PoolIsClosed:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		p1.Release()
+		assert.Equal(t, pool.Stats().Open, 0)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxOpen = 3
+		cfg.MaxIdle = 2
+		pool := NewPool(cfg)
+		defer pool.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p, err := pool.Get()
+				assert.True(t, err.IsOk())
+				time.Sleep(time.Millisecond)
+				p.Release()
+			}()
+		}
+		wg.Wait()
+
+		stats := pool.Stats()
+		assert.True(t, stats.Open <= 2)
+		assert.Equal(t, stats.InUse, 0)
+	})
+}
+
+func TestPooledDataSrc(t *testing.T) {
+	t.Run("create and close data conns", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		cfg := newTestPoolConfig(l)
+		cfg.MaxOpen = 1
+		cfg.WaitTimeout = 10 * time.Millisecond
+
+		ds := NewPooledDataSrc(cfg, func(p *Pooled[*poolRes]) (DataConn, errs.Err) {
+			return &PoolDataConn{p: p}, errs.Ok()
+		})
+		assert.Equal(t, ds.Stats().MaxOpen, 1)
+		assert.Nil(t, ds.DataConnType())
+
+		_, err := ds.CreateDataConn()
+		switch err.Reason().(type) {
+		case PoolIsClosed:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.True(t, ds.Setup(&AsyncGroup{}).IsOk())
+
+		dc1, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, dc1.(*PoolDataConn).p.Resource().id, 1)
+
+		_, err = ds.CreateDataConn()
+		switch err.Reason().(type) {
+		case PoolWaitTimeout:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		dc1.Close()
+
+		dc2, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, dc2.(*PoolDataConn).p.Resource().id, 1)
+		dc2.Close()
+
+		stats := ds.Stats()
+		assert.Equal(t, stats.Open, 1)
+		assert.Equal(t, stats.Idle, 1)
+
+		ds.Close()
+
+		log := l.logger.Front()
+		assert.Equal(t, log.Value, "open 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("fail to create data conn", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		ds := NewPooledDataSrc(newTestPoolConfig(l),
+			func(p *Pooled[*poolRes]) (DataConn, errs.Err) {
+				return nil, errs.New("fail")
+			})
+		assert.True(t, ds.Setup(&AsyncGroup{}).IsOk())
+		defer ds.Close()
+
+		_, err := ds.CreateDataConn()
+		assert.Equal(t, err.Reason(), "fail")
+
+		stats := ds.Stats()
+		assert.Equal(t, stats.InUse, 0)
+		assert.Equal(t, stats.Idle, 1)
+	})
+
+	t.Run("use in data hub", func(t *testing.T) {
+		l := &poolLogger{logger: list.New()}
+		ds := NewPooledDataSrc(newTestPoolConfig(l),
+			func(p *Pooled[*poolRes]) (*PoolDataConn, errs.Err) {
+				return &PoolDataConn{p: p}, errs.Ok()
+			})
+		assert.Equal(t, ds.DataConnType(), reflect.TypeFor[*PoolDataConn]())
+
+		hub := NewDataHub()
+		hub.Uses("pool", ds)
+
+		for i := 0; i < 3; i++ {
+			err := Txn(hub, func(data DataHub) errs.Err {
+				dc, err := GetDataConnByType[*PoolDataConn](data)
+				assert.Equal(t, dc.p.Resource().id, 1)
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}
+
+		stats := ds.Stats()
+		assert.Equal(t, stats.Open, 1)
+		assert.Equal(t, stats.Idle, 1)
+
+		hub.Close()
+		assert.Equal(t, ds.Stats().Open, 0)
+	})
+}
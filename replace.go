@@ -0,0 +1,133 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+type /* error reasons */ (
+	// NoGlobalDataSrcToReplace represents an error reason indicating that Replace was called with
+	// a name with which no global data source has been set up.
+	NoGlobalDataSrcToReplace struct {
+		Name string
+	}
+
+	// FailToSetupReplacingDataSrc represents an error reason indicating that the data source
+	// passed to Replace failed to initialize. It wraps the errors returned by the setup of the
+	// data source and by its asynchronous tasks. In this case, the replaced data source remains
+	// in use.
+	FailToSetupReplacingDataSrc struct {
+		Name   string
+		Errors []ErrEntry
+	}
+)
+
+var replaceMutex sync.Mutex
+
+// Replace replaces the global data source registered with the specified name with the specified
+// data source at runtime, for example to rotate credentials of a database or to move to another
+// cluster without restarting the application.
+//
+// Before the global data sources are fixed by Setup, SetupWithOrder or NewDataHub, this function
+// works like Uses with ReplaceDuplicates, regardless of the policy set by SetDuplicatePolicy.
+//
+// After that, this function sets up the new data source, and if it succeeds, switches to it
+// atomically so that DataHubs created afterward use it. DataHubs created before keep using the
+// replaced data source, which is closed when all DataConns created from it are closed.
+// If such a DataHub creates its first DataConn from the name after the replaced data source has
+// been closed, the DataConn is created from the new one.
+// If the setup of the new data source fails, this function returns an error with the reason
+// FailToSetupReplacingDataSrc and the replaced data source remains in use.
+//
+// This function can be called concurrently with NewDataHub, Run, Txn and other calls of this
+// function, but must not be called concurrently with Setup or SetupWithOrder.
+func Replace(name string, ds DataSrc) errs.Err {
+	replaceMutex.Lock()
+	defer replaceMutex.Unlock()
+
+	if !globalDataSrcsFixed.Load() {
+		globalDataSrcManager.remove(name)
+		globalDataSrcManager.listUnready = append(globalDataSrcManager.listUnready,
+			dataSrcContainer{name: name, ds: ds, ref: newDataSrcRef()})
+		return errs.Ok()
+	}
+
+	globalDataSrcMutex.RLock()
+	index := globalDataSrcManager.indexOfReady(name)
+	globalDataSrcMutex.RUnlock()
+	if index < 0 {
+		return errs.New(NoGlobalDataSrcToReplace{Name: name})
+	}
+
+	cont := dataSrcContainer{name: name, ds: ds, ref: newDataSrcRef()}
+	if !isLazyDataSrc(ds) {
+		if errors := globalDataSrcManager.setupOne(cont); len(errors) > 0 {
+			return errs.New(FailToSetupReplacingDataSrc{Name: name, Errors: errors})
+		}
+	}
+
+	globalDataSrcMutex.Lock()
+	// Shutdown may have been called while setting up the new data source.
+	index = globalDataSrcManager.indexOfReady(name)
+	if index < 0 {
+		globalDataSrcMutex.Unlock()
+		globalDataSrcManager.closeDataSrc(&cont)
+		return errs.New(NoGlobalDataSrcToReplace{Name: name})
+	}
+	old := globalDataSrcManager.listReady[index]
+	globalDataSrcManager.listReady[index] = cont
+
+	closeNow := old.ref.markRetired(&globalDataSrcManager, old)
+	retiredDataSrcRefs = slices.DeleteFunc(retiredDataSrcRefs, (*dataSrcRef).isClosed)
+	retiredDataSrcRefs = append(retiredDataSrcRefs, old.ref)
+	globalDataSrcMutex.Unlock()
+
+	// The replaced data source is closed out of the lock, so that a slow Close does not block
+	// NewDataHub, Health and so on.
+	if closeNow {
+		old.ref.close()
+	}
+	return errs.Ok()
+}
+
+func (mgr *dataSrcManager) indexOfReady(name string) int {
+	for i := len(mgr.listReady) - 1; i >= 0; i-- {
+		if mgr.listReady[i].name == name && mgr.listReady[i].ds != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (mgr *dataSrcManager) setupOne(cont dataSrcContainer) []ErrEntry {
+	ag := AsyncGroup{}
+	ag._name = cont.name
+	start := time.Now()
+	if err := cont.ds.Setup(&ag); err.IsNotOk() {
+		ag.addErr(ag._index, ag._name, err)
+	}
+	errors := ag.join()
+
+	if mgr.observer.isActive() {
+		err := errs.Ok()
+		if len(errors) > 0 {
+			err = errors[0].Err
+		}
+		mgr.observer.notify(Event{
+			Phase:        PhaseSetupDataSrc,
+			DataConnName: cont.name,
+			DataSrcType:  dataSrcTypeNameOf(cont.ds),
+			Start:        start,
+			Duration:     time.Since(start),
+			Err:          err,
+		})
+	}
+	return errors
+}
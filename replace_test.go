@@ -0,0 +1,340 @@
+package sabi
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func TestReplace(t *testing.T) {
+	t.Run("before Setup", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Replace("foo", NewMyDataSrc(2, Failure_None, logger))
+		assert.True(t, err.IsOk())
+
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("no data source to replace", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		err := Setup()
+		defer Shutdown()
+		assert.True(t, err.IsOk())
+
+		err = Replace("bar", NewMyDataSrc(2, Failure_None, logger))
+		switch r := err.Reason().(type) {
+		case NoGlobalDataSrcToReplace:
+			assert.Equal(t, r.Name, "bar")
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("fail to set up the new data source", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			err = Replace("foo", NewMyDataSrc(2, Failure_Setup, logger))
+			switch r := err.Reason().(type) {
+			case FailToSetupReplacingDataSrc:
+				assert.Equal(t, r.Name, "foo")
+				assert.Len(t, r.Errors, 1)
+				assert.Equal(t, r.Errors[0].Name, "foo")
+			default:
+				assert.Fail(t, err.Error())
+			}
+
+			err = Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2 failed")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("close the replaced data source immediately if not in use", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			err = Replace("foo", NewMyDataSrc(2, Failure_None, logger))
+			assert.True(t, err.IsOk())
+
+			err = Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("not block other calls while closing the replaced data source", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		closing := make(chan struct{})
+		unblock := make(chan struct{})
+		Uses("foo", &HookedDataSrc{
+			MyDataSrc: NewMyDataSrc(1, Failure_None, logger),
+			onClose: func() {
+				close(closing)
+				<-unblock
+			},
+		})
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			replaced := make(chan errs.Err)
+			go func() {
+				replaced <- Replace("foo", NewMyDataSrc(2, Failure_None, logger))
+			}()
+			<-closing
+
+			err = Run(NewDataHub(), func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+			assert.True(t, Health(context.Background(), time.Second).IsHealthy())
+
+			close(unblock)
+			assert.True(t, (<-replaced).IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("close the replaced data source after its last DataConn is closed", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			err = Txn(NewDataHub(), func(data any) errs.Err {
+				if _, err := GetDataConn[*MyDataConn](data, "foo"); err.IsNotOk() {
+					return err
+				}
+
+				err := Replace("foo", NewMyDataSrc(2, Failure_None, logger))
+				assert.True(t, err.IsOk())
+
+				err = Run(NewDataHub(), func(data any) errs.Err {
+					_, err := GetDataConn[*MyDataConn](data, "foo")
+					return err
+				})
+				assert.True(t, err.IsOk())
+
+				_, err = GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("existing DataHub uses the new data source after the old one is closed", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		func() {
+			err := Setup()
+			defer Shutdown()
+			assert.True(t, err.IsOk())
+
+			hub := NewDataHub()
+			defer hub.Close()
+
+			err = Replace("foo", NewMyDataSrc(2, Failure_None, logger))
+			assert.True(t, err.IsOk())
+
+			err = Run(hub, func(data any) errs.Err {
+				_, err := GetDataConn[*MyDataConn](data, "foo")
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 2")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 2")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("replace concurrently with running DataHubs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		const n = 10
+		dsList := make([]*CountingDataSrc, n)
+		for i := range dsList {
+			dsList[i] = &CountingDataSrc{}
+		}
+
+		Uses("foo", dsList[0])
+		err := Setup()
+		assert.True(t, err.IsOk())
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					err := Txn(NewDataHub(), func(data any) errs.Err {
+						_, err := GetDataConn[*BadDataConn](data, "foo")
+						return err
+					})
+					assert.True(t, err.IsOk())
+				}
+			}()
+		}
+		for i := 1; i < n; i++ {
+			err := Replace("foo", dsList[i])
+			assert.True(t, err.IsOk())
+		}
+		wg.Wait()
+
+		for i := 0; i < n-1; i++ {
+			assert.Equal(t, dsList[i].setups.Load(), int32(1))
+			assert.Equal(t, dsList[i].closes.Load(), int32(1))
+		}
+		assert.Equal(t, dsList[n-1].closes.Load(), int32(0))
+
+		Shutdown()
+		assert.Equal(t, dsList[n-1].closes.Load(), int32(1))
+	})
+}
@@ -0,0 +1,332 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// Package sabihttp provides DataSrc and DataConn implementations and helpers to use the sabi
+// framework together with the net/http package.
+package sabihttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type /* error reasons */ (
+	// InvalidBaseURL represents an error reason indicating that the base URL given to a
+	// ClientDataSrc could not be parsed as an absolute URL.
+	InvalidBaseURL struct {
+		BaseURL string
+	}
+
+	// FailToCreateRequest represents an error reason indicating that an HTTP request could not
+	// be built from the given Request, for example because its path is not a valid URL.
+	FailToCreateRequest struct {
+		Method string
+		Path   string
+	}
+
+	// FailToSendRequest represents an error reason indicating that an HTTP request could not be
+	// sent or its response body could not be read, typically because of a network failure.
+	FailToSendRequest struct {
+		Method string
+		URL    string
+	}
+
+	// UnexpectedStatus represents an error reason indicating that an HTTP request was sent
+	// successfully but the server responded with a status code outside of the 2xx range.
+	UnexpectedStatus struct {
+		Method     string
+		URL        string
+		StatusCode int
+	}
+)
+
+// Request is a description of an HTTP request sent through a ClientDataConn.
+// Path is resolved against the base URL of the ClientDataSrc, so it can be either a relative
+// path or an absolute URL.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Response is an HTTP response whose body has already been read and closed.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// ClientDataSrc is a DataSrc which creates ClientDataConn instances sending HTTP requests to
+// an external service located at a base URL.
+//
+// It makes outbound API calls follow the same commit and rollback lifecycle as writes to
+// databases: requests can be sent immediately, deferred to the commit or post-commit phase, or
+// registered as compensations which are sent only when the transaction is rolled back.
+type ClientDataSrc struct {
+	baseURL string
+	base    *url.URL
+	client  *http.Client
+}
+
+// NewClientDataSrc creates a new ClientDataSrc which sends requests to the service located at
+// baseURL by using the given http.Client.
+// If client is nil, a new http.Client with default settings is used.
+// In tests, the URL and the client of an httptest.Server can be passed to this function.
+func NewClientDataSrc(baseURL string, client *http.Client) *ClientDataSrc {
+	return &ClientDataSrc{baseURL: baseURL, client: client}
+}
+
+// Setup parses the base URL and prepares the http.Client.
+// It returns an error with the reason InvalidBaseURL if the base URL is not an absolute URL.
+func (ds *ClientDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err {
+	u, e := url.Parse(ds.baseURL)
+	if e != nil {
+		return errs.New(InvalidBaseURL{BaseURL: ds.baseURL}, e)
+	}
+	if !u.IsAbs() {
+		return errs.New(InvalidBaseURL{BaseURL: ds.baseURL})
+	}
+	ds.base = u
+
+	if ds.client == nil {
+		ds.client = &http.Client{}
+	}
+	return errs.Ok()
+}
+
+// Close closes the idle connections kept by the http.Client.
+func (ds *ClientDataSrc) Close() {
+	if ds.client != nil {
+		ds.client.CloseIdleConnections()
+	}
+}
+
+// CreateDataConn creates a new ClientDataConn sharing the http.Client of this data source.
+func (ds *ClientDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	return &ClientDataConn{
+		ctx:    context.Background(),
+		base:   ds.base,
+		client: ds.client,
+	}, errs.Ok()
+}
+
+// DataConnType returns the type of the DataConns created by this data source, *ClientDataConn.
+func (ds *ClientDataSrc) DataConnType() reflect.Type {
+	return reflect.TypeFor[*ClientDataConn]()
+}
+
+// Phase indicates the transaction phase in which a request was sent by a ClientDataConn.
+type Phase uint8
+
+// The following constants represent the phases in which a request can be sent.
+const (
+	// PhaseImmediate indicates that the request was sent directly by Send.
+	PhaseImmediate Phase = iota
+	// PhaseCommit indicates that the request was deferred by AddCommit and sent in the commit
+	// phase.
+	PhaseCommit
+	// PhasePostCommit indicates that the request was deferred by AddPostCommit and sent in the
+	// post-commit phase.
+	PhasePostCommit
+	// PhaseRollback indicates that the request was registered by AddRollback and sent as a
+	// compensation in the rollback phase.
+	PhaseRollback
+)
+
+// String returns the string representation of the Phase.
+func (phase Phase) String() string {
+	var s string
+	switch phase {
+	case PhaseImmediate:
+		s = "Immediate"
+	case PhaseCommit:
+		s = "Commit"
+	case PhasePostCommit:
+		s = "PostCommit"
+	case PhaseRollback:
+		s = "Rollback"
+	}
+	return s
+}
+
+// Record is an entry of the history of requests sent by a ClientDataConn.
+// StatusCode is zero if no response was received.
+type Record struct {
+	Phase      Phase
+	Request    Request
+	StatusCode int
+	Err        errs.Err
+}
+
+// ClientDataConn is a DataConn which sends HTTP requests to an external service.
+//
+// Requests sent with Send are executed immediately, which is suitable for reading data.
+// Requests registered with AddCommit or AddPostCommit are deferred until the corresponding
+// phase of the transaction, and requests registered with AddRollback are sent in reverse order
+// only when the transaction is rolled back.
+// Because a failed transaction may be rerun, deferred and compensating requests should be
+// idempotent.
+type ClientDataConn struct {
+	ctx         context.Context
+	base        *url.URL
+	client      *http.Client
+	commits     []Request
+	postCommits []Request
+	rollbacks   []Request
+	records     []Record
+	committed   bool
+}
+
+// SetContext sets the context used for requests sent after this call.
+func (conn *ClientDataConn) SetContext(ctx context.Context) {
+	conn.ctx = ctx
+}
+
+// Send sends the given request immediately and returns its response.
+// It returns an error with the reason UnexpectedStatus if the status code of the response is
+// not in the 2xx range; in that case the response is also returned.
+func (conn *ClientDataConn) Send(req Request) (Response, errs.Err) {
+	return conn.send(PhaseImmediate, req)
+}
+
+// AddCommit registers a request which is sent in the commit phase of the transaction.
+func (conn *ClientDataConn) AddCommit(req Request) {
+	conn.commits = append(conn.commits, req)
+}
+
+// AddPostCommit registers a request which is sent in the post-commit phase of the transaction.
+func (conn *ClientDataConn) AddPostCommit(req Request) {
+	conn.postCommits = append(conn.postCommits, req)
+}
+
+// AddRollback registers a compensating request which is sent when the transaction is rolled
+// back. Compensating requests are sent in the reverse order of their registration.
+func (conn *ClientDataConn) AddRollback(req Request) {
+	conn.rollbacks = append(conn.rollbacks, req)
+}
+
+// Records returns the history of requests sent by this connection.
+func (conn *ClientDataConn) Records() []Record {
+	return conn.records
+}
+
+// PreCommit does nothing and returns Ok.
+func (conn *ClientDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+
+// Commit sends the requests registered by AddCommit in order. It stops at the first failed
+// request and returns its error.
+func (conn *ClientDataConn) Commit(ag *sabi.AsyncGroup) errs.Err {
+	for _, req := range conn.commits {
+		if _, err := conn.send(PhaseCommit, req); err.IsNotOk() {
+			return err
+		}
+	}
+	conn.committed = len(conn.commits) > 0
+	return errs.Ok()
+}
+
+// PostCommit sends the requests registered by AddPostCommit in order. It stops at the first
+// failed request and returns its error.
+func (conn *ClientDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err {
+	for _, req := range conn.postCommits {
+		if _, err := conn.send(PhasePostCommit, req); err.IsNotOk() {
+			return err
+		}
+	}
+	return errs.Ok()
+}
+
+// IsCommitted returns true if the requests registered by AddCommit have been sent successfully
+// and there is no compensating request registered by AddRollback.
+//
+// Since sabi does not roll back a committed DataConn, this method returns false while
+// compensating requests are registered, so that they are sent if another DataConn fails to
+// commit. It also returns false if there is no request to send in the commit phase.
+func (conn *ClientDataConn) IsCommitted() bool {
+	return conn.committed && len(conn.rollbacks) == 0
+}
+
+// Rollback sends the compensating requests registered by AddRollback in reverse order.
+// Even if a request fails, the remaining ones are sent, and the first error is returned.
+func (conn *ClientDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err {
+	first := errs.Ok()
+	for i := len(conn.rollbacks) - 1; i >= 0; i-- {
+		if _, err := conn.send(PhaseRollback, conn.rollbacks[i]); err.IsNotOk() && first.IsOk() {
+			first = err
+		}
+	}
+	return first
+}
+
+// OnTxnFailure does nothing.
+func (conn *ClientDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+
+// Close discards the registered requests.
+func (conn *ClientDataConn) Close() {
+	conn.commits = nil
+	conn.postCommits = nil
+	conn.rollbacks = nil
+}
+
+func (conn *ClientDataConn) send(phase Phase, req Request) (Response, errs.Err) {
+	res, err := conn.do(req)
+	conn.records = append(conn.records, Record{
+		Phase: phase, Request: req, StatusCode: res.StatusCode, Err: err})
+	return res, err
+}
+
+func (conn *ClientDataConn) do(req Request) (Response, errs.Err) {
+	ref, e := url.Parse(req.Path)
+	if e != nil {
+		return Response{}, errs.New(FailToCreateRequest{Method: req.Method, Path: req.Path}, e)
+	}
+	u := ref
+	if conn.base != nil {
+		u = conn.base.ResolveReference(ref)
+	}
+
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+	r, e := http.NewRequestWithContext(conn.ctx, req.Method, u.String(), body)
+	if e != nil {
+		return Response{}, errs.New(FailToCreateRequest{Method: req.Method, Path: req.Path}, e)
+	}
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+
+	resp, e := conn.client.Do(r)
+	if e != nil {
+		return Response{}, errs.New(FailToSendRequest{Method: req.Method, URL: u.String()}, e)
+	}
+	defer resp.Body.Close()
+
+	b, e := io.ReadAll(resp.Body)
+	if e != nil {
+		return Response{StatusCode: resp.StatusCode, Header: resp.Header},
+			errs.New(FailToSendRequest{Method: req.Method, URL: u.String()}, e)
+	}
+
+	res := Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: b}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, errs.New(UnexpectedStatus{
+			Method: req.Method, URL: u.String(), StatusCode: resp.StatusCode})
+	}
+	return res, errs.Ok()
+}
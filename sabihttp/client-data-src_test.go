@@ -0,0 +1,348 @@
+package sabihttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabihttp"
+)
+
+type serverLog struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *serverLog) add(s string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, s)
+}
+
+func (l *serverLog) get() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func newServer(log *serverLog) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		log.add(r.Method + " " + r.URL.Path + " " + string(b))
+		switch {
+		case strings.Contains(r.URL.Path, "fail"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == http.MethodGet:
+			w.Header().Set("X-Test", "yes")
+			_, _ = w.Write([]byte("value of " + r.URL.Path))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+type ApiData interface {
+	GetItem(id string) (string, errs.Err)
+	PutItem(id, value string) errs.Err
+}
+
+type ApiDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da *ApiDataAcc) GetItem(id string) (string, errs.Err) {
+	conn, err := sabi.GetDataConn[*sabihttp.ClientDataConn](da, "api")
+	if err.IsNotOk() {
+		return "", err
+	}
+	res, err := conn.Send(sabihttp.Request{Method: http.MethodGet, Path: "/items/" + id})
+	if err.IsNotOk() {
+		return "", err
+	}
+	return string(res.Body), errs.Ok()
+}
+
+func (da *ApiDataAcc) PutItem(id, value string) errs.Err {
+	conn, err := sabi.GetDataConn[*sabihttp.ClientDataConn](da, "api")
+	if err.IsNotOk() {
+		return err
+	}
+	conn.AddCommit(sabihttp.Request{
+		Method: http.MethodPut, Path: "/items/" + id, Body: []byte(value)})
+	conn.AddRollback(sabihttp.Request{
+		Method: http.MethodDelete, Path: "/items/" + id})
+	conn.AddPostCommit(sabihttp.Request{
+		Method: http.MethodPost, Path: "/notify/" + id})
+	return errs.Ok()
+}
+
+type ApiDataHub struct {
+	sabi.DataHub
+	*ApiDataAcc
+}
+
+func NewApiDataHub() ApiDataHub {
+	hub := sabi.NewDataHub()
+	return ApiDataHub{DataHub: hub, ApiDataAcc: &ApiDataAcc{DataAcc: hub}}
+}
+
+type FailingDataSrc struct{}
+
+func (ds FailingDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds FailingDataSrc) Close()                             {}
+func (ds FailingDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	return &FailingDataConn{}, errs.Ok()
+}
+
+type FailingDataConn struct{}
+
+func (conn *FailingDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err  { return errs.Ok() }
+func (conn *FailingDataConn) Commit(ag *sabi.AsyncGroup) errs.Err     { return errs.New("db error") }
+func (conn *FailingDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *FailingDataConn) IsCommitted() bool                       { return false }
+func (conn *FailingDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err   { return errs.Ok() }
+func (conn *FailingDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+func (conn *FailingDataConn) Close() {}
+
+func TestClientDataSrc(t *testing.T) {
+	t.Run("setup with invalid base url", func(t *testing.T) {
+		ds := sabihttp.NewClientDataSrc("/relative", nil)
+		err := ds.Setup(&sabi.AsyncGroup{})
+		switch rsn := err.Reason().(type) {
+		case sabihttp.InvalidBaseURL:
+			assert.Equal(t, rsn.BaseURL, "/relative")
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		ds = sabihttp.NewClientDataSrc(":bad", nil)
+		err = ds.Setup(&sabi.AsyncGroup{})
+		switch err.Reason().(type) {
+		case sabihttp.InvalidBaseURL:
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("send immediately", func(t *testing.T) {
+		log := &serverLog{}
+		srv := newServer(log)
+		defer srv.Close()
+
+		ds := sabihttp.NewClientDataSrc(srv.URL, srv.Client())
+		assert.True(t, ds.Setup(&sabi.AsyncGroup{}).IsOk())
+		defer ds.Close()
+
+		dc, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		conn := dc.(*sabihttp.ClientDataConn)
+		defer conn.Close()
+
+		res, err := conn.Send(sabihttp.Request{
+			Method: http.MethodGet, Path: "items/1", Header: http.Header{"X-A": {"a"}}})
+		assert.True(t, err.IsOk())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		assert.Equal(t, res.Header.Get("X-Test"), "yes")
+		assert.Equal(t, string(res.Body), "value of /items/1")
+
+		res, err = conn.Send(sabihttp.Request{Method: http.MethodGet, Path: "/fail"})
+		assert.Equal(t, res.StatusCode, http.StatusInternalServerError)
+		switch rsn := err.Reason().(type) {
+		case sabihttp.UnexpectedStatus:
+			assert.Equal(t, rsn.Method, http.MethodGet)
+			assert.Equal(t, rsn.URL, srv.URL+"/fail")
+			assert.Equal(t, rsn.StatusCode, http.StatusInternalServerError)
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		_, err = conn.Send(sabihttp.Request{Method: "BAD METHOD", Path: "/"})
+		switch err.Reason().(type) {
+		case sabihttp.FailToCreateRequest:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		recs := conn.Records()
+		assert.Len(t, recs, 3)
+		assert.Equal(t, recs[0].Phase, sabihttp.PhaseImmediate)
+		assert.Equal(t, recs[0].StatusCode, http.StatusOK)
+		assert.True(t, recs[0].Err.IsOk())
+		assert.Equal(t, recs[1].StatusCode, http.StatusInternalServerError)
+		assert.True(t, recs[1].Err.IsNotOk())
+		assert.Equal(t, recs[2].StatusCode, 0)
+
+		assert.Equal(t, log.get(), []string{"GET /items/1 ", "GET /fail "})
+	})
+
+	t.Run("fail to send", func(t *testing.T) {
+		srv := newServer(&serverLog{})
+		url := srv.URL
+		srv.Close()
+
+		ds := sabihttp.NewClientDataSrc(url, nil)
+		assert.True(t, ds.Setup(&sabi.AsyncGroup{}).IsOk())
+		defer ds.Close()
+
+		dc, _ := ds.CreateDataConn()
+		_, err := dc.(*sabihttp.ClientDataConn).Send(
+			sabihttp.Request{Method: http.MethodGet, Path: "/"})
+		switch rsn := err.Reason().(type) {
+		case sabihttp.FailToSendRequest:
+			assert.Equal(t, rsn.URL, url+"/")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("txn and commit", func(t *testing.T) {
+		log := &serverLog{}
+		srv := newServer(log)
+		defer srv.Close()
+
+		hub := NewApiDataHub()
+		defer hub.Close()
+		hub.Uses("api", sabihttp.NewClientDataSrc(srv.URL, srv.Client()))
+
+		err := sabi.Txn(hub, func(data ApiData) errs.Err {
+			v, err := data.GetItem("1")
+			if err.IsNotOk() {
+				return err
+			}
+			return data.PutItem("2", v)
+		})
+		assert.True(t, err.IsOk())
+
+		assert.Equal(t, log.get(), []string{
+			"GET /items/1 ",
+			"PUT /items/2 value of /items/1",
+			"POST /notify/2 ",
+		})
+	})
+
+	t.Run("txn and rollback by logic failure", func(t *testing.T) {
+		log := &serverLog{}
+		srv := newServer(log)
+		defer srv.Close()
+
+		hub := NewApiDataHub()
+		defer hub.Close()
+		hub.Uses("api", sabihttp.NewClientDataSrc(srv.URL, srv.Client()))
+
+		err := sabi.Txn(hub, func(data ApiData) errs.Err {
+			if err := data.PutItem("1", "a"); err.IsNotOk() {
+				return err
+			}
+			if err := data.PutItem("2", "b"); err.IsNotOk() {
+				return err
+			}
+			return errs.New("logic error")
+		})
+		assert.Equal(t, err.Reason(), "logic error")
+
+		assert.Equal(t, log.get(), []string{
+			"DELETE /items/2 ",
+			"DELETE /items/1 ",
+		})
+	})
+
+	t.Run("txn and rollback by commit failure", func(t *testing.T) {
+		log := &serverLog{}
+		srv := newServer(log)
+		defer srv.Close()
+
+		hub := NewApiDataHub()
+		defer hub.Close()
+		hub.Uses("api", sabihttp.NewClientDataSrc(srv.URL, srv.Client()))
+
+		err := sabi.Txn(hub, func(data ApiData) errs.Err {
+			if err := data.PutItem("1", "a"); err.IsNotOk() {
+				return err
+			}
+			return data.PutItem("fail", "b")
+		})
+		switch err.Reason().(type) {
+		case sabi.FailToCommitDataConn:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.Equal(t, log.get(), []string{
+			"PUT /items/1 a",
+			"PUT /items/fail b",
+			"DELETE /items/fail ",
+			"DELETE /items/1 ",
+		})
+	})
+	t.Run("txn and rollback by commit failure of another DataConn", func(t *testing.T) {
+		log := &serverLog{}
+		srv := newServer(log)
+		defer srv.Close()
+
+		hub := NewApiDataHub()
+		defer hub.Close()
+		hub.Uses("api", sabihttp.NewClientDataSrc(srv.URL, srv.Client()))
+		hub.Uses("db", FailingDataSrc{})
+
+		err := sabi.Txn(hub, func(data ApiData) errs.Err {
+			conn, err := sabi.GetDataConn[*sabihttp.ClientDataConn](data, "api")
+			if err.IsNotOk() {
+				return err
+			}
+			_, err = conn.Send(sabihttp.Request{Method: http.MethodPost, Path: "/reserve"})
+			if err.IsNotOk() {
+				return err
+			}
+			conn.AddRollback(sabihttp.Request{Method: http.MethodDelete, Path: "/reserve"})
+
+			if err := data.PutItem("1", "a"); err.IsNotOk() {
+				return err
+			}
+
+			_, err = sabi.GetDataConn[*FailingDataConn](data, "db")
+			return err
+		})
+		switch err.Reason().(type) {
+		case sabi.FailToCommitDataConn:
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.Equal(t, log.get(), []string{
+			"POST /reserve ",
+			"PUT /items/1 a",
+			"DELETE /items/1 ",
+			"DELETE /reserve ",
+		})
+	})
+
+	t.Run("not committed if nothing to commit", func(t *testing.T) {
+		ds := sabihttp.NewClientDataSrc("http://localhost", nil)
+		assert.True(t, ds.Setup(nil).IsOk())
+		dc, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		conn := dc.(*sabihttp.ClientDataConn)
+
+		assert.True(t, conn.Commit(nil).IsOk())
+		assert.False(t, conn.IsCommitted())
+	})
+
+	t.Run("get data conn by type", func(t *testing.T) {
+		hub := sabi.NewDataHub()
+		defer hub.Close()
+		hub.Uses("api", sabihttp.NewClientDataSrc("http://localhost", nil))
+
+		err := sabi.Run(hub, func(data sabi.DataHub) errs.Err {
+			_, err := sabi.GetDataConnByType[*sabihttp.ClientDataConn](data)
+			return err
+		})
+		assert.True(t, err.IsOk())
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabihttp
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type /* error reasons */ (
+	// FailToReadRequestBody represents an error reason indicating that the body of an inbound
+	// HTTP request could not be read.
+	FailToReadRequestBody struct {
+		Method string
+		Path   string
+	}
+)
+
+// ExchangeDataSrc is a DataSrc which exposes an inbound HTTP request and its response to
+// DataAcc implementations through ExchangeDataConn.
+//
+// It is a sabi.IoDataSrc whose input is the request and whose output is the response, and is
+// registered to a DataHub per request, so that the input and output of a controller are routed
+// directly to the data access layer. The response written through ExchangeDataConn is buffered
+// and becomes visible by Response only after the transaction is committed, or the logic executed
+// by sabi.Run succeeds, so a response is never sent partially for a transaction which was rolled
+// back.
+type ExchangeDataSrc struct {
+	*sabi.IoDataSrc[*http.Request, Response]
+	req      *http.Request
+	body     []byte
+	bodyRead bool
+	bodyErr  errs.Err
+}
+
+// NewExchangeDataSrc creates a new ExchangeDataSrc for the inbound request.
+func NewExchangeDataSrc(req *http.Request) *ExchangeDataSrc {
+	return &ExchangeDataSrc{
+		IoDataSrc: sabi.NewIoDataSrc[*http.Request, Response](req, nil),
+		req:       req,
+		bodyErr:   errs.Ok(),
+	}
+}
+
+// CreateDataConn creates a new ExchangeDataConn for the request of this data source.
+func (ds *ExchangeDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	dc, err := ds.IoDataSrc.CreateDataConn()
+	if err.IsNotOk() {
+		return nil, err
+	}
+	return &ExchangeDataConn{IoDataConn: dc.(*sabi.IoDataConn[*http.Request, Response]), ds: ds},
+		errs.Ok()
+}
+
+// DataConnType returns the type of the DataConns created by this data source, *ExchangeDataConn.
+func (ds *ExchangeDataSrc) DataConnType() reflect.Type {
+	return reflect.TypeFor[*ExchangeDataConn]()
+}
+
+// Response returns the committed response and true, or a zero Response and false if no
+// response has been committed.
+func (ds *ExchangeDataSrc) Response() (Response, bool) {
+	return ds.Output()
+}
+
+func (ds *ExchangeDataSrc) readBody() ([]byte, errs.Err) {
+	if !ds.bodyRead {
+		ds.bodyRead = true
+		if ds.req.Body != nil {
+			b, e := io.ReadAll(ds.req.Body)
+			if e != nil {
+				ds.bodyErr = errs.New(
+					FailToReadRequestBody{Method: ds.req.Method, Path: ds.req.URL.Path}, e)
+			}
+			ds.body = b
+		}
+	}
+	return ds.body, ds.bodyErr
+}
+
+// ExchangeDataConn is a DataConn to read an inbound HTTP request and to write its response.
+// It is a sabi.IoDataConn, so the whole response can also be set by SetOutput.
+type ExchangeDataConn struct {
+	*sabi.IoDataConn[*http.Request, Response]
+	ds *ExchangeDataSrc
+}
+
+// Request returns the inbound request.
+func (conn *ExchangeDataConn) Request() *http.Request {
+	return conn.Input()
+}
+
+// Body returns the body of the inbound request. The body is read only once and shared among
+// the DataConns of the same data source.
+// It returns an error with the reason FailToReadRequestBody if the body could not be read.
+func (conn *ExchangeDataConn) Body() ([]byte, errs.Err) {
+	return conn.ds.readBody()
+}
+
+// SetStatus sets the status code of the response.
+func (conn *ExchangeDataConn) SetStatus(statusCode int) {
+	res, _ := conn.Output()
+	res.StatusCode = statusCode
+	conn.SetOutput(res)
+}
+
+// ResponseHeader returns the header of the response, which can be modified.
+func (conn *ExchangeDataConn) ResponseHeader() http.Header {
+	res, _ := conn.Output()
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+	conn.SetOutput(res)
+	return res.Header
+}
+
+// Write appends b to the body of the response.
+func (conn *ExchangeDataConn) Write(b []byte) {
+	res, _ := conn.Output()
+	res.Body = append(res.Body, b...)
+	conn.SetOutput(res)
+}
@@ -0,0 +1,157 @@
+package sabihttp_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabihttp"
+)
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestExchangeDataSrc(t *testing.T) {
+	t.Run("read request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("abc"))
+		req.Header.Set("X-A", "a")
+
+		ds := sabihttp.NewExchangeDataSrc(req)
+		assert.True(t, ds.Setup(&sabi.AsyncGroup{}).IsOk())
+		defer ds.Close()
+
+		dc1, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		conn1 := dc1.(*sabihttp.ExchangeDataConn)
+		dc2, _ := ds.CreateDataConn()
+		conn2 := dc2.(*sabihttp.ExchangeDataConn)
+
+		assert.Equal(t, conn1.Request(), req)
+		assert.Equal(t, conn1.Request().Header.Get("X-A"), "a")
+
+		b, err := conn1.Body()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, string(b), "abc")
+		b, err = conn2.Body()
+		assert.True(t, err.IsOk())
+		assert.Equal(t, string(b), "abc")
+	})
+
+	t.Run("fail to read request body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/items", errReader{})
+
+		ds := sabihttp.NewExchangeDataSrc(req)
+		dc, _ := ds.CreateDataConn()
+
+		_, err := dc.(*sabihttp.ExchangeDataConn).Body()
+		switch rsn := err.Reason().(type) {
+		case sabihttp.FailToReadRequestBody:
+			assert.Equal(t, rsn.Method, http.MethodPost)
+			assert.Equal(t, rsn.Path, "/items")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("commit response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+
+		ds := sabihttp.NewExchangeDataSrc(req)
+		dc, _ := ds.CreateDataConn()
+		conn := dc.(*sabihttp.ExchangeDataConn)
+
+		_, ok := ds.Response()
+		assert.False(t, ok)
+
+		conn.SetStatus(http.StatusCreated)
+		conn.ResponseHeader().Set("X-B", "b")
+		conn.Write([]byte("12"))
+		conn.Write([]byte("3"))
+
+		_, ok = ds.Response()
+		assert.False(t, ok)
+
+		ag := &sabi.AsyncGroup{}
+		assert.True(t, conn.PreCommit(ag).IsOk())
+		assert.False(t, conn.IsCommitted())
+		assert.True(t, conn.Commit(ag).IsOk())
+		assert.True(t, conn.IsCommitted())
+		_, ok = ds.Response()
+		assert.False(t, ok)
+		assert.True(t, conn.PostCommit(ag).IsOk())
+		conn.Close()
+
+		res, ok := ds.Response()
+		assert.True(t, ok)
+		assert.Equal(t, res.StatusCode, http.StatusCreated)
+		assert.Equal(t, res.Header.Get("X-B"), "b")
+		assert.Equal(t, string(res.Body), "123")
+	})
+
+	t.Run("roll back response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+
+		ds := sabihttp.NewExchangeDataSrc(req)
+		dc, _ := ds.CreateDataConn()
+		conn := dc.(*sabihttp.ExchangeDataConn)
+
+		conn.Write([]byte("123"))
+
+		ag := &sabi.AsyncGroup{}
+		assert.True(t, conn.Rollback(ag).IsOk())
+		conn.OnTxnFailure(ag, nil)
+		assert.True(t, conn.Commit(ag).IsOk())
+
+		_, ok := ds.Response()
+		assert.False(t, ok)
+	})
+
+	t.Run("respond on run", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+		ds := sabihttp.NewExchangeDataSrc(req)
+
+		hub := sabi.NewDataHub()
+		defer hub.Close()
+		hub.Uses("http", ds)
+
+		err := sabi.Run(hub, func(data sabi.DataHub) errs.Err {
+			conn, err := sabi.GetDataConn[*sabihttp.ExchangeDataConn](data, "http")
+			if err.IsNotOk() {
+				return err
+			}
+			conn.Write([]byte("123"))
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		res, ok := ds.Response()
+		assert.True(t, ok)
+		assert.Equal(t, string(res.Body), "123")
+	})
+
+	t.Run("get data conn by type", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+
+		hub := sabi.NewDataHub()
+		defer hub.Close()
+		hub.Uses("http", sabihttp.NewExchangeDataSrc(req))
+
+		err := sabi.Run(hub, func(data sabi.DataHub) errs.Err {
+			conn, err := sabi.GetDataConnByType[*sabihttp.ExchangeDataConn](data)
+			if err.IsNotOk() {
+				return err
+			}
+			assert.Same(t, conn.Request(), req)
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabihttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sttk/sabi"
+)
+
+// HealthBody is the JSON body of the responses of ReadinessHandler.
+type HealthBody struct {
+	// Status is "ok" if the endpoint succeeds, otherwise "unavailable".
+	Status string `json:"status"`
+	// Ready is the same as sabi.HealthReport.Ready.
+	Ready bool `json:"ready"`
+	// Checks are the results of the health checks of the global data sources.
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the JSON representation of sabi.HealthStatus.
+type HealthCheck struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// LivenessBody is the JSON body of the responses of LivenessHandler.
+type LivenessBody struct {
+	// Status is always "ok".
+	Status string `json:"status"`
+}
+
+// LivenessHandler returns an http.Handler for a liveness endpoint, which reports only that the
+// process is running and serving requests.
+// It always responds with http.StatusOK and a LivenessBody in JSON, and does not check the global
+// data sources, since a failure of a downstream service should not cause the process to be
+// restarted.
+// Use ReadinessHandler to check them.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(LivenessBody{Status: "ok"})
+	})
+}
+
+var checkHealth = sabi.Health
+
+// ReadinessHandler returns an http.Handler for a readiness endpoint, which checks the health of
+// the global data sources with sabi.Health and the timeout.
+// It responds with http.StatusOK if the global data sources have been set up and no data source
+// is unhealthy, otherwise with http.StatusServiceUnavailable, and a HealthBody in JSON.
+func ReadinessHandler(timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := checkHealth(r.Context(), timeout)
+
+		body := HealthBody{
+			Status: "ok",
+			Ready:  report.Ready,
+			Checks: make([]HealthCheck, len(report.Statuses)),
+		}
+		for i, status := range report.Statuses {
+			body.Checks[i] = HealthCheck{Name: status.Name, State: status.State.String()}
+			if status.Err.IsNotOk() {
+				body.Checks[i].Error = fmt.Sprintf("%v", status.Err.Reason())
+			}
+		}
+
+		statusCode := http.StatusOK
+		if !report.IsReady() {
+			body.Status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,74 @@
+package sabihttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type PingFailed struct{}
+
+func serveHealth(h http.Handler, report sabi.HealthReport) *httptest.ResponseRecorder {
+	checkHealth = func(ctx context.Context, timeout time.Duration) sabi.HealthReport {
+		return report
+	}
+	defer func() { checkHealth = sabi.Health }()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	return w
+}
+
+func TestLivenessHandler(t *testing.T) {
+	h := LivenessHandler()
+
+	t.Run("not ready", func(t *testing.T) {
+		w := serveHealth(h, sabi.HealthReport{Statuses: []sabi.HealthStatus{}})
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json")
+		assert.JSONEq(t, w.Body.String(), `{"status":"ok"}`)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		w := serveHealth(h, sabi.HealthReport{Ready: true, Statuses: []sabi.HealthStatus{
+			{Name: "foo", State: sabi.Unhealthy, Err: errs.New(PingFailed{})},
+		}})
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.JSONEq(t, w.Body.String(), `{"status":"ok"}`)
+	})
+}
+
+func TestReadinessHandler(t *testing.T) {
+	h := ReadinessHandler(time.Second)
+
+	t.Run("not ready", func(t *testing.T) {
+		w := serveHealth(h, sabi.HealthReport{Statuses: []sabi.HealthStatus{}})
+		assert.Equal(t, w.Code, http.StatusServiceUnavailable)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json")
+		assert.JSONEq(t, w.Body.String(), `{"status":"unavailable","ready":false,"checks":[]}`)
+	})
+
+	t.Run("ready and healthy", func(t *testing.T) {
+		w := serveHealth(h, sabi.HealthReport{Ready: true, Statuses: []sabi.HealthStatus{
+			{Name: "foo", State: sabi.Healthy, Err: errs.Ok()},
+		}})
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.JSONEq(t, w.Body.String(),
+			`{"status":"ok","ready":true,"checks":[{"name":"foo","state":"Healthy"}]}`)
+	})
+
+	t.Run("ready but unhealthy", func(t *testing.T) {
+		w := serveHealth(h, sabi.HealthReport{Ready: true, Statuses: []sabi.HealthStatus{
+			{Name: "foo", State: sabi.Unhealthy, Err: errs.New(PingFailed{})},
+		}})
+		assert.Equal(t, w.Code, http.StatusServiceUnavailable)
+		assert.JSONEq(t, w.Body.String(), `{"status":"unavailable","ready":true,"checks":[`+
+			`{"name":"foo","state":"Unhealthy","error":"{}"}]}`)
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabihttp
+
+import (
+	"net/http"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+// DefaultExchangeName is the name with which TxnHandler registers an ExchangeDataSrc to a
+// DataHub if Config.ExchangeName is empty.
+const DefaultExchangeName = "http"
+
+// StatusCoder is an interface which an error reason can implement to specify the HTTP status
+// code of the response for the error.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Config is the configuration of TxnHandler.
+type Config struct {
+	// NewDataHub creates a DataHub for a request. This field is required.
+	NewDataHub func(r *http.Request) sabi.DataHub
+
+	// Uses registers request-local data sources to the DataHub, in addition to the
+	// ExchangeDataSrc. This field is optional.
+	Uses func(hub sabi.DataHub, r *http.Request)
+
+	// ExchangeName is the name with which the ExchangeDataSrc is registered.
+	// If this field is empty, DefaultExchangeName is used.
+	ExchangeName string
+
+	// StatusOf maps an error returned by Txn to an HTTP status code.
+	// If this field is nil, DefaultStatusOf is used.
+	StatusOf func(err errs.Err) int
+
+	// WriteError writes the response for an error returned by Txn.
+	// If this field is nil, the response is written by http.Error with the status text.
+	WriteError func(w http.ResponseWriter, r *http.Request, err errs.Err, statusCode int)
+
+	// OnPostCommitError is called with an error returned by Txn with the reason
+	// sabi.FailToPostCommitDataConn. Since such a transaction has been committed, the committed
+	// response is written instead of an error response. This field is optional; if it is nil,
+	// the error is reported only to the Listener and the logger set to sabi.
+	OnPostCommitError func(r *http.Request, err errs.Err)
+
+	// Interceptors are passed to Txn.
+	Interceptors []sabi.Interceptor
+}
+
+// DefaultStatusOf returns the HTTP status code for the error: the one returned by StatusCode
+// if the reason of the error implements StatusCoder, otherwise http.StatusInternalServerError.
+func DefaultStatusOf(err errs.Err) int {
+	if sc, ok := err.Reason().(StatusCoder); ok {
+		return sc.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// TxnHandler returns an http.Handler which executes the logic in a transaction per request.
+//
+// For each request, the handler creates a DataHub with Config.NewDataHub, registers an
+// ExchangeDataSrc for the request and the data sources registered by Config.Uses, and executes
+// the logic with sabi.Txn. If the transaction succeeds, the committed response of the
+// ExchangeDataSrc is written; its status code defaults to http.StatusOK if it has a body or
+// http.StatusNoContent otherwise. The committed response is written also if the transaction
+// is committed but fails in the post-commit phase, and the error is passed to
+// Config.OnPostCommitError. If the transaction fails otherwise, the error is mapped to a status
+// code with Config.StatusOf and written with Config.WriteError.
+// The spans of the transaction are created in the trace of the context of the request, and the
+// DataHub is closed when the request ends.
+func TxnHandler[D any](cfg Config, logic func(D) errs.Err) http.Handler {
+	name := cfg.ExchangeName
+	if len(name) == 0 {
+		name = DefaultExchangeName
+	}
+	statusOf := cfg.StatusOf
+	if statusOf == nil {
+		statusOf = DefaultStatusOf
+	}
+	writeError := cfg.WriteError
+	if writeError == nil {
+		writeError = writeErrorText
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub := cfg.NewDataHub(r)
+		defer hub.Close()
+		hub.SetTraceContext(r.Context())
+
+		ds := NewExchangeDataSrc(r)
+		hub.Uses(name, ds)
+		if cfg.Uses != nil {
+			cfg.Uses(hub, r)
+		}
+
+		if err := sabi.Txn(hub, logic, cfg.Interceptors...); err.IsNotOk() {
+			if _, ok := err.Reason().(sabi.FailToPostCommitDataConn); !ok {
+				writeError(w, r, err, statusOf(err))
+				return
+			}
+			if cfg.OnPostCommitError != nil {
+				cfg.OnPostCommitError(r, err)
+			}
+		}
+
+		res, _ := ds.Response()
+		for k, vs := range res.Header {
+			w.Header()[k] = vs
+		}
+		statusCode := res.StatusCode
+		if statusCode == 0 {
+			if len(res.Body) > 0 {
+				statusCode = http.StatusOK
+			} else {
+				statusCode = http.StatusNoContent
+			}
+		}
+		w.WriteHeader(statusCode)
+		if len(res.Body) > 0 {
+			_, _ = w.Write(res.Body)
+		}
+	})
+}
+
+func writeErrorText(w http.ResponseWriter, r *http.Request, err errs.Err, statusCode int) {
+	http.Error(w, http.StatusText(statusCode), statusCode)
+}
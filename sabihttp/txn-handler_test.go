@@ -0,0 +1,312 @@
+package sabihttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabihttp"
+)
+
+type ItemNotFound struct {
+	Id string
+}
+
+func (ItemNotFound) StatusCode() int {
+	return http.StatusNotFound
+}
+
+type MemDataSrc struct {
+	items          map[string]string
+	failPostCommit bool
+}
+
+func (ds *MemDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *MemDataSrc) Close()                             {}
+func (ds *MemDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	return &MemDataConn{ds: ds, pending: make(map[string]string)}, errs.Ok()
+}
+
+type MemDataConn struct {
+	ds      *MemDataSrc
+	pending map[string]string
+}
+
+func (conn *MemDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *MemDataConn) Commit(ag *sabi.AsyncGroup) errs.Err {
+	for k, v := range conn.pending {
+		conn.ds.items[k] = v
+	}
+	return errs.Ok()
+}
+func (conn *MemDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err {
+	if conn.ds.failPostCommit {
+		return errs.New("notify error")
+	}
+	return errs.Ok()
+}
+func (conn *MemDataConn) IsCommitted() bool                     { return false }
+func (conn *MemDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *MemDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+func (conn *MemDataConn) Close() {}
+
+type ItemData interface {
+	ItemId() string
+	NewValue() (string, errs.Err)
+	GetItem(id string) (string, errs.Err)
+	PutItem(id, value string) errs.Err
+	Respond(value string)
+}
+
+type ItemDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da *ItemDataAcc) exchange() *sabihttp.ExchangeDataConn {
+	conn, _ := sabi.GetDataConn[*sabihttp.ExchangeDataConn](da, "http")
+	return conn
+}
+
+func (da *ItemDataAcc) ItemId() string {
+	return strings.TrimPrefix(da.exchange().Request().URL.Path, "/items/")
+}
+
+func (da *ItemDataAcc) NewValue() (string, errs.Err) {
+	b, err := da.exchange().Body()
+	return string(b), err
+}
+
+func (da *ItemDataAcc) GetItem(id string) (string, errs.Err) {
+	conn, err := sabi.GetDataConn[*MemDataConn](da, "mem")
+	if err.IsNotOk() {
+		return "", err
+	}
+	v, ok := conn.ds.items[id]
+	if !ok {
+		return "", errs.New(ItemNotFound{Id: id})
+	}
+	return v, errs.Ok()
+}
+
+func (da *ItemDataAcc) PutItem(id, value string) errs.Err {
+	conn, err := sabi.GetDataConn[*MemDataConn](da, "mem")
+	if err.IsNotOk() {
+		return err
+	}
+	conn.pending[id] = value
+	return errs.Ok()
+}
+
+func (da *ItemDataAcc) Respond(value string) {
+	conn := da.exchange()
+	conn.ResponseHeader().Set("Content-Type", "text/plain")
+	conn.Write([]byte(value))
+}
+
+type ItemDataHub struct {
+	sabi.DataHub
+	*ItemDataAcc
+}
+
+func NewItemDataHub(r *http.Request) sabi.DataHub {
+	hub := sabi.NewDataHub()
+	return ItemDataHub{DataHub: hub, ItemDataAcc: &ItemDataAcc{DataAcc: hub}}
+}
+
+func GetItemLogic(data ItemData) errs.Err {
+	v, err := data.GetItem(data.ItemId())
+	if err.IsNotOk() {
+		return err
+	}
+	data.Respond(v)
+	return errs.Ok()
+}
+
+func ReplaceItemLogic(data ItemData) errs.Err {
+	id := data.ItemId()
+	old, err := data.GetItem(id)
+	if err.IsNotOk() {
+		return err
+	}
+	v, err := data.NewValue()
+	if err.IsNotOk() {
+		return err
+	}
+	if len(v) == 0 {
+		return errs.New("empty value")
+	}
+	if err := data.PutItem(id, v); err.IsNotOk() {
+		return err
+	}
+	data.Respond(old)
+	return errs.Ok()
+}
+
+func TestTxnHandler(t *testing.T) {
+	newConfig := func(mem *MemDataSrc) sabihttp.Config {
+		return sabihttp.Config{
+			NewDataHub: NewItemDataHub,
+			Uses: func(hub sabi.DataHub, r *http.Request) {
+				hub.Uses("mem", mem)
+			},
+		}
+	}
+
+	t.Run("get item", func(t *testing.T) {
+		mem := &MemDataSrc{items: map[string]string{"1": "a"}}
+		h := sabihttp.TxnHandler(newConfig(mem), GetItemLogic)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, w.Body.String(), "a")
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/2", nil))
+		assert.Equal(t, w.Code, http.StatusNotFound)
+		assert.Equal(t, w.Body.String(), "Not Found\n")
+	})
+
+	t.Run("replace item", func(t *testing.T) {
+		mem := &MemDataSrc{items: map[string]string{"1": "a"}}
+		h := sabihttp.TxnHandler(newConfig(mem), ReplaceItemLogic)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader("b")))
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Body.String(), "a")
+		assert.Equal(t, mem.items["1"], "b")
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader("")))
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+		assert.Equal(t, w.Body.String(), "Internal Server Error\n")
+		assert.Equal(t, mem.items["1"], "b")
+	})
+
+	t.Run("respond committed response on post-commit failure", func(t *testing.T) {
+		mem := &MemDataSrc{items: map[string]string{"1": "a"}, failPostCommit: true}
+		cfg := newConfig(mem)
+		var postCommitErr errs.Err
+		cfg.OnPostCommitError = func(r *http.Request, err errs.Err) {
+			assert.Equal(t, r.URL.Path, "/items/1")
+			postCommitErr = err
+		}
+		h := sabihttp.TxnHandler(cfg, ReplaceItemLogic)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader("b")))
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Body.String(), "a")
+		assert.Equal(t, mem.items["1"], "b")
+
+		switch rsn := postCommitErr.Reason().(type) {
+		case sabi.FailToPostCommitDataConn:
+			assert.Equal(t, rsn.Errors[0].Name, "mem")
+			assert.Equal(t, rsn.Errors[0].Err.Reason(), "notify error")
+		default:
+			assert.Fail(t, postCommitErr.Error())
+		}
+
+		cfg.OnPostCommitError = nil
+		h = sabihttp.TxnHandler(cfg, ReplaceItemLogic)
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader("c")))
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Body.String(), "b")
+		assert.Equal(t, mem.items["1"], "c")
+	})
+
+	t.Run("custom status mapping and error writer", func(t *testing.T) {
+		mem := &MemDataSrc{items: map[string]string{"1": "a"}}
+		cfg := newConfig(mem)
+		cfg.StatusOf = func(err errs.Err) int {
+			if err.Reason() == "empty value" {
+				return http.StatusBadRequest
+			}
+			return sabihttp.DefaultStatusOf(err)
+		}
+		cfg.WriteError = func(w http.ResponseWriter, r *http.Request, err errs.Err, code int) {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(r.URL.Path))
+		}
+		h := sabihttp.TxnHandler(cfg, ReplaceItemLogic)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader("")))
+		assert.Equal(t, w.Code, http.StatusBadRequest)
+		assert.Equal(t, w.Body.String(), "/items/1")
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/9", strings.NewReader("")))
+		assert.Equal(t, w.Code, http.StatusNotFound)
+	})
+
+	t.Run("no content, exchange name and interceptors", func(t *testing.T) {
+		called := false
+		cfg := sabihttp.Config{
+			NewDataHub:   NewItemDataHub,
+			ExchangeName: "exchange",
+			Interceptors: []sabi.Interceptor{
+				func(hub sabi.DataHub, next func() errs.Err) errs.Err {
+					called = true
+					return next()
+				},
+			},
+		}
+		h := sabihttp.TxnHandler(cfg, func(data sabi.DataHub) errs.Err {
+			conn, err := sabi.GetDataConn[*sabihttp.ExchangeDataConn](data, "exchange")
+			if err.IsNotOk() {
+				return err
+			}
+			conn.ResponseHeader().Set("X-A", "a")
+			return errs.Ok()
+		})
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/items/1", nil))
+		assert.Equal(t, w.Code, http.StatusNoContent)
+		assert.Equal(t, w.Header().Get("X-A"), "a")
+		assert.True(t, called)
+	})
+
+	t.Run("trace in the context of the request", func(t *testing.T) {
+		r := sabi.NewSpanRecorder()
+		cfg := newConfig(&MemDataSrc{items: map[string]string{"1": "a"}})
+		cfg.NewDataHub = func(req *http.Request) sabi.DataHub {
+			hub := NewItemDataHub(req)
+			hub.SetTracer(r)
+			return hub
+		}
+		h := sabihttp.TxnHandler(cfg, GetItemLogic)
+
+		ctx, span := r.Start(context.Background(), "request", time.Now())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil).WithContext(ctx))
+		span.End(time.Now())
+		assert.Equal(t, w.Code, http.StatusOK)
+
+		spans := r.Spans()
+		assert.Equal(t, spans[0].Name, "request")
+		assert.Equal(t, spans[1].Name, sabi.SpanNameTxn)
+		assert.Equal(t, spans[1].ParentId, spans[0].Id)
+	})
+
+	t.Run("fail to cast data hub", func(t *testing.T) {
+		h := sabihttp.TxnHandler(sabihttp.Config{NewDataHub: NewItemDataHub},
+			func(data interface{ Unknown() }) errs.Err { return errs.Ok() })
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabitest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sttk/sabi"
+)
+
+// Op represents an operation of a DataSrc or a DataConn which is recorded in the event log.
+type Op string
+
+// The following constants represent the operations recorded in the event log.
+const (
+	OpSetup          Op = "Setup"
+	OpCreateDataConn Op = "CreateDataConn"
+	OpCloseDataSrc   Op = "CloseDataSrc"
+	OpPreCommit      Op = "PreCommit"
+	OpCommit         Op = "Commit"
+	OpPostCommit     Op = "PostCommit"
+	OpRollback       Op = "Rollback"
+	OpOnTxnFailure   Op = "OnTxnFailure"
+	OpCloseDataConn  Op = "CloseDataConn"
+)
+
+// Outcome represents how an operation recorded in the event log ended.
+type Outcome string
+
+// The following constants represent the outcomes of operations.
+const (
+	Succeeded Outcome = "succeeded"
+	Failed    Outcome = "failed"
+	Panicked  Outcome = "panicked"
+)
+
+// Event is an entry of the event log.
+type Event struct {
+	// Name is the name of the fake data source which recorded this event.
+	Name string
+	// Op is the operation.
+	Op Op
+	// Outcome is how the operation ended.
+	Outcome Outcome
+}
+
+// String returns the string representation of this event in the form of "name Op" for a
+// successful operation, or "name Op failed" and "name Op panicked" otherwise.
+func (ev Event) String() string {
+	s := ev.Name + " " + string(ev.Op)
+	if ev.Outcome != Succeeded {
+		s += " " + string(ev.Outcome)
+	}
+	return s
+}
+
+var (
+	eventMutex sync.Mutex
+	events     []Event
+)
+
+func record(name string, op Op, outcome Outcome) {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	events = append(events, Event{Name: name, Op: op, Outcome: outcome})
+}
+
+// Events returns a copy of the global event log, which holds the events recorded by all fakes in
+// the order in which the operations ended.
+func Events() []Event {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	return append([]Event(nil), events...)
+}
+
+// EventStrings returns the string representations of the events in the global event log.
+func EventStrings() []string {
+	evs := Events()
+	a := make([]string, len(evs))
+	for i, ev := range evs {
+		a[i] = ev.String()
+	}
+	return a
+}
+
+// ResetEvents clears the global event log. It should be called at the beginning of each test.
+func ResetEvents() {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	events = nil
+}
+
+// AssertEvents checks that the string representations of the events in the global event log
+// are equal to the expected ones, and reports an error to t if not.
+func AssertEvents(t testing.TB, expected ...string) bool {
+	t.Helper()
+
+	actual := EventStrings()
+	if len(actual) == len(expected) {
+		same := true
+		for i := range actual {
+			if actual[i] != expected[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	t.Errorf("events are not equal:\nexpected: %s\nactual  : %s",
+		formatStrings(expected), formatStrings(actual))
+	return false
+}
+
+// AssertEventsInOrder checks that the global event log contains the events represented by the
+// expected strings in this order, possibly with other events between them, and reports an error
+// to t if not.
+func AssertEventsInOrder(t testing.TB, expected ...string) bool {
+	t.Helper()
+
+	actual := EventStrings()
+	i := 0
+	for _, s := range actual {
+		if i < len(expected) && s == expected[i] {
+			i++
+		}
+	}
+	if i == len(expected) {
+		return true
+	}
+	t.Errorf("events do not contain %q in order:\nexpected: %s\nactual  : %s",
+		expected[i], formatStrings(expected), formatStrings(actual))
+	return false
+}
+
+// AssertReport checks that reports contain the report for the data connection named name and
+// that its cause state and rollback state are equal to the expected ones, and reports an error
+// to t if not.
+func AssertReport(
+	t testing.TB,
+	reports []sabi.TxnFailureReport,
+	name string,
+	cause sabi.TxnFailureCauseState,
+	rollback sabi.TxnFailureRollbackState,
+) bool {
+	t.Helper()
+
+	for i := range reports {
+		if reports[i].DataConnName != name {
+			continue
+		}
+		if reports[i].Cause.State != cause || reports[i].Rollback.State != rollback {
+			t.Errorf("report of %q is not equal:\nexpected: %s, %s\nactual  : %s, %s", name,
+				cause, rollback, reports[i].Cause.State, reports[i].Rollback.State)
+			return false
+		}
+		return true
+	}
+	t.Errorf("no report of %q", name)
+	return false
+}
+
+// AssertRecovery checks that reports contain the report for the data connection named name and
+// that the recovery for commit derived from it is equal to the expected one, and reports an
+// error to t if not.
+func AssertRecovery(
+	t testing.TB,
+	reports []sabi.TxnFailureReport,
+	name string,
+	recovery sabi.TxnFailureRecovery,
+) bool {
+	t.Helper()
+
+	for i := range reports {
+		if reports[i].DataConnName != name {
+			continue
+		}
+		if actual := reports[i].RecoveryForCommit(); actual != recovery {
+			t.Errorf("recovery of %q is not equal:\nexpected: %s\nactual  : %s", name,
+				recovery, actual)
+			return false
+		}
+		return true
+	}
+	t.Errorf("no report of %q", name)
+	return false
+}
+
+func formatStrings(a []string) string {
+	return "[" + strings.Join(a, ", ") + "]"
+}
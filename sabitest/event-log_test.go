@@ -0,0 +1,118 @@
+package sabitest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabitest"
+)
+
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestEvent(t *testing.T) {
+	t.Run("String", func(t *testing.T) {
+		ev := sabitest.Event{Name: "a", Op: sabitest.OpCommit, Outcome: sabitest.Succeeded}
+		assert.Equal(t, ev.String(), "a Commit")
+
+		ev.Outcome = sabitest.Failed
+		assert.Equal(t, ev.String(), "a Commit failed")
+
+		ev.Outcome = sabitest.Panicked
+		assert.Equal(t, ev.String(), "a Commit panicked")
+	})
+}
+
+func TestEventLog(t *testing.T) {
+	t.Run("Events and ResetEvents", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a").On(sabitest.OpSetup, sabitest.Fail("boom"))
+		assert.True(t, ds.Setup(nil).IsNotOk())
+		ds.Close()
+
+		assert.Equal(t, sabitest.Events(), []sabitest.Event{
+			{Name: "a", Op: sabitest.OpSetup, Outcome: sabitest.Failed},
+			{Name: "a", Op: sabitest.OpCloseDataSrc, Outcome: sabitest.Succeeded},
+		})
+		assert.Equal(t, sabitest.EventStrings(), []string{"a Setup failed", "a CloseDataSrc"})
+
+		sabitest.ResetEvents()
+		assert.Len(t, sabitest.Events(), 0)
+	})
+
+	t.Run("AssertEvents", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a")
+		_ = ds.Setup(nil)
+		ds.Close()
+
+		rt := &recordingT{}
+		assert.True(t, sabitest.AssertEvents(rt, "a Setup", "a CloseDataSrc"))
+		assert.Len(t, rt.errors, 0)
+
+		assert.False(t, sabitest.AssertEvents(rt, "a Setup"))
+		assert.False(t, sabitest.AssertEvents(rt, "a CloseDataSrc", "a Setup"))
+		assert.Len(t, rt.errors, 2)
+		assert.Equal(t, rt.errors[0], "events are not equal:\n"+
+			"expected: [a Setup]\n"+
+			"actual  : [a Setup, a CloseDataSrc]")
+	})
+
+	t.Run("AssertEventsInOrder", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a")
+		_ = ds.Setup(nil)
+		_, _ = ds.CreateDataConn()
+		ds.Close()
+
+		rt := &recordingT{}
+		assert.True(t, sabitest.AssertEventsInOrder(rt, "a Setup", "a CloseDataSrc"))
+		assert.True(t, sabitest.AssertEventsInOrder(rt))
+		assert.Len(t, rt.errors, 0)
+
+		assert.False(t, sabitest.AssertEventsInOrder(rt, "a CloseDataSrc", "a Setup"))
+		assert.Len(t, rt.errors, 1)
+		assert.Equal(t, rt.errors[0], "events do not contain \"a Setup\" in order:\n"+
+			"expected: [a CloseDataSrc, a Setup]\n"+
+			"actual  : [a Setup, a CreateDataConn, a CloseDataSrc]")
+	})
+
+	t.Run("AssertReport and AssertRecovery", func(t *testing.T) {
+		reports := []sabi.TxnFailureReport{
+			{
+				DataConnName: "a",
+				Cause:        sabi.TxnFailureCause{State: sabi.CommitFailure},
+				Rollback: sabi.TxnFailureRollback{
+					State: sabi.NoneByRolledBack,
+				},
+			},
+		}
+
+		rt := &recordingT{}
+		assert.True(t, sabitest.AssertReport(rt, reports, "a",
+			sabi.CommitFailure, sabi.NoneByRolledBack))
+		assert.True(t, sabitest.AssertRecovery(rt, reports, "a", reports[0].RecoveryForCommit()))
+		assert.Len(t, rt.errors, 0)
+
+		assert.False(t, sabitest.AssertReport(rt, reports, "a",
+			sabi.LogicFailure, sabi.NoneByRolledBack))
+		assert.False(t, sabitest.AssertReport(rt, reports, "b",
+			sabi.CommitFailure, sabi.NoneByRolledBack))
+		assert.False(t, sabitest.AssertRecovery(rt, reports, "b", reports[0].RecoveryForCommit()))
+		assert.Len(t, rt.errors, 3)
+		assert.Equal(t, rt.errors[1], "no report of \"b\"")
+	})
+}
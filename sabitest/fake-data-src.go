@@ -0,0 +1,189 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// Package sabitest provides helpers to test logic functions, DataAcc implementations and
+// DataSrc implementations built on the sabi framework.
+package sabitest
+
+import (
+	"sync"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type actionKind uint8
+
+const (
+	succeed actionKind = iota
+	fail
+	block
+	panicking
+)
+
+// Action is a scripted behavior of an operation of a fake.
+type Action struct {
+	kind    actionKind
+	reason  any
+	release <-chan struct{}
+	value   any
+}
+
+// Succeed returns an Action which makes an operation succeed. This is the default action.
+func Succeed() Action {
+	return Action{kind: succeed}
+}
+
+// Fail returns an Action which makes an operation fail with an error having the reason.
+// For operations which cannot return an error, namely CloseDataSrc, OnTxnFailure and
+// CloseDataConn, this action is the same as Succeed.
+func Fail(reason any) Action {
+	return Action{kind: fail, reason: reason}
+}
+
+// Block returns an Action which makes an operation block until the release channel is closed,
+// then succeed.
+func Block(release <-chan struct{}) Action {
+	return Action{kind: block, release: release}
+}
+
+// Panic returns an Action which makes an operation panic with the value.
+func Panic(value any) Action {
+	return Action{kind: panicking, value: value}
+}
+
+// FakeDataSrc is a DataSrc whose operations and the operations of its DataConns can be scripted
+// to succeed, fail, block or panic. All operations are recorded in the global event log.
+type FakeDataSrc struct {
+	name    string
+	mutex   sync.Mutex
+	actions map[Op]Action
+	reports []sabi.TxnFailureReport
+}
+
+// NewFakeDataSrc creates a new FakeDataSrc whose events are recorded with the name.
+// The name is usually the same as the one used to register the data source.
+func NewFakeDataSrc(name string) *FakeDataSrc {
+	return &FakeDataSrc{name: name, actions: make(map[Op]Action)}
+}
+
+// On scripts the action of the operation, which applies to this data source and all DataConns
+// created by it. This method returns this data source for chaining.
+func (ds *FakeDataSrc) On(op Op, action Action) *FakeDataSrc {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.actions[op] = action
+	return ds
+}
+
+// Reports returns the TxnFailureReports received by OnTxnFailure of the DataConns created by
+// this data source most recently.
+func (ds *FakeDataSrc) Reports() []sabi.TxnFailureReport {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return append([]sabi.TxnFailureReport(nil), ds.reports...)
+}
+
+func (ds *FakeDataSrc) act(op Op) errs.Err {
+	ds.mutex.Lock()
+	action, ok := ds.actions[op]
+	ds.mutex.Unlock()
+	if !ok {
+		action = Succeed()
+	}
+
+	switch action.kind {
+	case fail:
+		err := errs.New(action.reason)
+		switch op {
+		case OpCloseDataSrc, OpOnTxnFailure, OpCloseDataConn:
+			err = errs.Ok()
+			record(ds.name, op, Succeeded)
+		default:
+			record(ds.name, op, Failed)
+		}
+		return err
+	case block:
+		<-action.release
+	case panicking:
+		record(ds.name, op, Panicked)
+		panic(action.value)
+	}
+	record(ds.name, op, Succeeded)
+	return errs.Ok()
+}
+
+// Setup performs the scripted action of OpSetup.
+func (ds *FakeDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err {
+	return ds.act(OpSetup)
+}
+
+// Close performs the scripted action of OpCloseDataSrc.
+func (ds *FakeDataSrc) Close() {
+	_ = ds.act(OpCloseDataSrc)
+}
+
+// CreateDataConn performs the scripted action of OpCreateDataConn, and returns a new
+// FakeDataConn if it succeeds.
+func (ds *FakeDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	if err := ds.act(OpCreateDataConn); err.IsNotOk() {
+		return nil, err
+	}
+	return &FakeDataConn{ds: ds}, errs.Ok()
+}
+
+// FakeDataConn is a DataConn created by FakeDataSrc, whose operations perform the actions
+// scripted to the data source.
+type FakeDataConn struct {
+	ds        *FakeDataSrc
+	committed bool
+}
+
+// Name returns the name of the data source which created this connection.
+func (conn *FakeDataConn) Name() string {
+	return conn.ds.name
+}
+
+// PreCommit performs the scripted action of OpPreCommit.
+func (conn *FakeDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.act(OpPreCommit)
+}
+
+// Commit performs the scripted action of OpCommit.
+func (conn *FakeDataConn) Commit(ag *sabi.AsyncGroup) errs.Err {
+	err := conn.ds.act(OpCommit)
+	if err.IsOk() {
+		conn.committed = true
+	}
+	return err
+}
+
+// PostCommit performs the scripted action of OpPostCommit.
+func (conn *FakeDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.act(OpPostCommit)
+}
+
+// IsCommitted reports whether Commit of this connection has succeeded.
+func (conn *FakeDataConn) IsCommitted() bool {
+	return conn.committed
+}
+
+// Rollback performs the scripted action of OpRollback.
+func (conn *FakeDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.act(OpRollback)
+}
+
+// OnTxnFailure stores the reports to the data source and performs the scripted action of
+// OpOnTxnFailure.
+func (conn *FakeDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+	conn.ds.mutex.Lock()
+	conn.ds.reports = append([]sabi.TxnFailureReport(nil), reports...)
+	conn.ds.mutex.Unlock()
+	_ = conn.ds.act(OpOnTxnFailure)
+}
+
+// Close performs the scripted action of OpCloseDataConn.
+func (conn *FakeDataConn) Close() {
+	_ = conn.ds.act(OpCloseDataConn)
+}
@@ -0,0 +1,179 @@
+package sabitest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabitest"
+)
+
+func useConns(names ...string) func(sabi.DataHub) errs.Err {
+	return func(hub sabi.DataHub) errs.Err {
+		for _, name := range names {
+			if _, err := sabi.GetDataConn[*sabitest.FakeDataConn](hub, name); err.IsNotOk() {
+				return err
+			}
+		}
+		return errs.Ok()
+	}
+}
+
+func TestFakeDataSrc(t *testing.T) {
+	t.Run("succeed by default", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		hub := sabi.NewDataHub()
+		hub.Uses("a", sabitest.NewFakeDataSrc("a"))
+		hub.Uses("b", sabitest.NewFakeDataSrc("b"))
+
+		err := sabi.Txn(hub, useConns("a", "b"))
+		hub.Close()
+		assert.True(t, err.IsOk())
+
+		sabitest.AssertEvents(t,
+			"a Setup", "b Setup",
+			"a CreateDataConn", "b CreateDataConn",
+			"a PreCommit", "b PreCommit",
+			"a Commit", "b Commit",
+			"a PostCommit", "b PostCommit",
+			"b CloseDataConn", "a CloseDataConn",
+			"b CloseDataSrc", "a CloseDataSrc",
+		)
+	})
+
+	t.Run("fail on setup", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		hub := sabi.NewDataHub()
+		hub.Uses("a", sabitest.NewFakeDataSrc("a").On(sabitest.OpSetup, sabitest.Fail("boom")))
+
+		err := sabi.Txn(hub, useConns("a"))
+		hub.Close()
+		assert.True(t, err.IsNotOk())
+
+		sabitest.AssertEventsInOrder(t, "a Setup failed")
+		assert.NotContains(t, sabitest.EventStrings(), "a CreateDataConn")
+	})
+
+	t.Run("fail on create data conn", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		hub := sabi.NewDataHub()
+		hub.Uses("a",
+			sabitest.NewFakeDataSrc("a").On(sabitest.OpCreateDataConn, sabitest.Fail("boom")))
+
+		err := sabi.Txn(hub, useConns("a"))
+		hub.Close()
+		assert.True(t, err.IsNotOk())
+
+		sabitest.AssertEvents(t, "a Setup", "a CreateDataConn failed", "a CloseDataSrc")
+	})
+
+	t.Run("fail on commit and report", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		a := sabitest.NewFakeDataSrc("a")
+		b := sabitest.NewFakeDataSrc("b").On(sabitest.OpCommit, sabitest.Fail("boom"))
+
+		hub := sabi.NewDataHub()
+		hub.Uses("a", a)
+		hub.Uses("b", b)
+
+		err := sabi.Txn(hub, useConns("a", "b"))
+		hub.Close()
+		assert.True(t, err.IsNotOk())
+
+		sabitest.AssertEventsInOrder(t,
+			"a Commit", "b Commit failed", "b Rollback",
+			"a OnTxnFailure", "b OnTxnFailure",
+		)
+		assert.NotContains(t, sabitest.EventStrings(), "a Rollback")
+		assert.NotContains(t, sabitest.EventStrings(), "a PostCommit")
+
+		reports := a.Reports()
+		assert.Len(t, reports, 2)
+		sabitest.AssertReport(t, reports, "a",
+			sabi.NoneByCommitted, sabi.NoneByNotRolledBack)
+		sabitest.AssertReport(t, reports, "b",
+			sabi.CommitFailure, sabi.NoneByRolledBack)
+		assert.Equal(t, b.Reports(), reports)
+	})
+
+	t.Run("fail on rollback", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		a := sabitest.NewFakeDataSrc("a").On(sabitest.OpRollback, sabitest.Fail("cannot"))
+		b := sabitest.NewFakeDataSrc("b").On(sabitest.OpPreCommit, sabitest.Fail("boom"))
+
+		hub := sabi.NewDataHub()
+		hub.Uses("a", a)
+		hub.Uses("b", b)
+
+		err := sabi.Txn(hub, useConns("a", "b"))
+		hub.Close()
+		assert.True(t, err.IsNotOk())
+
+		sabitest.AssertEventsInOrder(t, "b PreCommit failed", "a Rollback failed", "b Rollback")
+		sabitest.AssertReport(t, a.Reports(), "a",
+			sabi.NoneByUncommitted, sabi.RollbackFailure)
+	})
+
+	t.Run("block until released", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		release := make(chan struct{})
+		hub := sabi.NewDataHub()
+		hub.Uses("a", sabitest.NewFakeDataSrc("a").On(sabitest.OpCommit, sabitest.Block(release)))
+
+		done := make(chan errs.Err)
+		go func() {
+			done <- sabi.Txn(hub, useConns("a"))
+		}()
+
+		select {
+		case <-done:
+			assert.Fail(t, "Txn must be blocked")
+		default:
+		}
+		assert.NotContains(t, sabitest.EventStrings(), "a Commit")
+
+		close(release)
+		err := <-done
+		hub.Close()
+		assert.True(t, err.IsOk())
+		sabitest.AssertEventsInOrder(t, "a PreCommit", "a Commit", "a PostCommit")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a").On(sabitest.OpCreateDataConn, sabitest.Panic("oops"))
+		assert.PanicsWithValue(t, "oops", func() {
+			_, _ = ds.CreateDataConn()
+		})
+		sabitest.AssertEvents(t, "a CreateDataConn panicked")
+	})
+
+	t.Run("fail on operations without error", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a").On(sabitest.OpCloseDataSrc, sabitest.Fail("boom"))
+		ds.Close()
+		sabitest.AssertEvents(t, "a CloseDataSrc")
+	})
+
+	t.Run("is committed", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFakeDataSrc("a")
+		conn, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		assert.False(t, conn.IsCommitted())
+		assert.Equal(t, conn.(*sabitest.FakeDataConn).Name(), "a")
+
+		assert.True(t, conn.Commit(nil).IsOk())
+		assert.True(t, conn.IsCommitted())
+	})
+}
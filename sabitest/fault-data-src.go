@@ -0,0 +1,229 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabitest
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type /* error reasons */ (
+	// InjectedFault represents an error reason indicating that an operation of a data source or
+	// a data connection failed because a fault was injected by FaultDataSrc.
+	// Call is the 1-based count of the calls of the operation on the data source.
+	// AfterCall is true if the wrapped operation was performed before failing.
+	InjectedFault struct {
+		Name      string
+		Op        Op
+		Call      int
+		AfterCall bool
+	}
+)
+
+// Fault is the configuration of a fault injected into an operation by FaultDataSrc.
+//
+// A call of the operation fails if it is the NthCall-th call, or with the Probability drawn from
+// the seeded random number generator of the FaultDataSrc.
+// For operations which cannot return an error, namely CloseDataSrc, OnTxnFailure and
+// CloseDataConn, only Latency takes effect.
+type Fault struct {
+	// Probability is the probability in [0, 1] that a call fails.
+	Probability float64
+	// NthCall is the 1-based number of the call which fails. Zero means no call fails by count.
+	NthCall int
+	// Latency is the duration to sleep before each call.
+	Latency time.Duration
+	// AfterCall makes a failing call perform the wrapped operation before returning an error.
+	// For OpCommit, this simulates a crash after the commit took effect.
+	AfterCall bool
+}
+
+// CrashAfterCommit returns a Fault which makes the NthCall-th commit take effect and then fail.
+func CrashAfterCommit(nthCall int) Fault {
+	return Fault{NthCall: nthCall, AfterCall: true}
+}
+
+// FaultDataSrc is a DataSrc which wraps another DataSrc and injects faults into the operations
+// of the data source and of the DataConns created by it.
+//
+// Whether a call fails is decided by a random number generator seeded on creation, so a test
+// run can be reproduced with the same seed. This data source implements sabi.DataSrcWrapper, so
+// sabi.Health checks the wrapped data source, and the DataConns created by it implement
+// sabi.DataConnWrapper, so sabi.GetDataConn returns the wrapped connections to DataAcc
+// implementations.
+type FaultDataSrc struct {
+	name   string
+	ds     sabi.DataSrc
+	mutex  sync.Mutex
+	rng    *rand.Rand
+	faults map[Op]Fault
+	calls  map[Op]int
+}
+
+// NewFaultDataSrc creates a new FaultDataSrc which wraps the data source ds.
+// The name is used in the reasons of injected errors, and the seed initializes the random number
+// generator.
+func NewFaultDataSrc(name string, ds sabi.DataSrc, seed int64) *FaultDataSrc {
+	return &FaultDataSrc{
+		name:   name,
+		ds:     ds,
+		rng:    rand.New(rand.NewSource(seed)),
+		faults: make(map[Op]Fault),
+		calls:  make(map[Op]int),
+	}
+}
+
+// Inject sets the fault of the operation, which replaces the fault set before.
+// This method returns this data source for chaining.
+func (ds *FaultDataSrc) Inject(op Op, fault Fault) *FaultDataSrc {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.faults[op] = fault
+	return ds
+}
+
+// Calls returns the number of the calls of the operation so far.
+func (ds *FaultDataSrc) Calls(op Op) int {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return ds.calls[op]
+}
+
+func (ds *FaultDataSrc) inject(op Op, call func() errs.Err) errs.Err {
+	ds.mutex.Lock()
+	ds.calls[op]++
+	n := ds.calls[op]
+	fault, ok := ds.faults[op]
+	failing := false
+	if ok {
+		failing = fault.NthCall == n
+		if fault.Probability > 0 && ds.rng.Float64() < fault.Probability {
+			failing = true
+		}
+	}
+	ds.mutex.Unlock()
+
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+
+	if !failing {
+		return call()
+	}
+	if fault.AfterCall {
+		if err := call(); err.IsNotOk() {
+			return err
+		}
+	}
+	return errs.New(InjectedFault{Name: ds.name, Op: op, Call: n, AfterCall: fault.AfterCall})
+}
+
+func (ds *FaultDataSrc) delay(op Op, call func()) {
+	ds.mutex.Lock()
+	ds.calls[op]++
+	fault := ds.faults[op]
+	ds.mutex.Unlock()
+
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	call()
+}
+
+// Unwrap returns the wrapped DataSrc.
+func (ds *FaultDataSrc) Unwrap() sabi.DataSrc {
+	return ds.ds
+}
+
+// Setup calls Setup of the wrapped data source unless a fault is injected.
+func (ds *FaultDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err {
+	return ds.inject(OpSetup, func() errs.Err {
+		return ds.ds.Setup(ag)
+	})
+}
+
+// Close calls Close of the wrapped data source.
+func (ds *FaultDataSrc) Close() {
+	ds.delay(OpCloseDataSrc, ds.ds.Close)
+}
+
+// CreateDataConn calls CreateDataConn of the wrapped data source unless a fault is injected, and
+// returns a FaultDataConn wrapping the created connection.
+func (ds *FaultDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	var conn sabi.DataConn
+	err := ds.inject(OpCreateDataConn, func() errs.Err {
+		c, err := ds.ds.CreateDataConn()
+		conn = c
+		return err
+	})
+	if err.IsNotOk() {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, err
+	}
+	return &FaultDataConn{ds: ds, conn: conn}, errs.Ok()
+}
+
+// FaultDataConn is a DataConn created by FaultDataSrc, which wraps a DataConn of the wrapped data
+// source and injects the faults configured to the FaultDataSrc.
+type FaultDataConn struct {
+	ds   *FaultDataSrc
+	conn sabi.DataConn
+}
+
+// Unwrap returns the wrapped DataConn.
+func (conn *FaultDataConn) Unwrap() sabi.DataConn {
+	return conn.conn
+}
+
+// PreCommit calls PreCommit of the wrapped connection unless a fault is injected.
+func (conn *FaultDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.inject(OpPreCommit, func() errs.Err {
+		return conn.conn.PreCommit(ag)
+	})
+}
+
+// Commit calls Commit of the wrapped connection unless a fault is injected.
+func (conn *FaultDataConn) Commit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.inject(OpCommit, func() errs.Err {
+		return conn.conn.Commit(ag)
+	})
+}
+
+// PostCommit calls PostCommit of the wrapped connection unless a fault is injected.
+func (conn *FaultDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.inject(OpPostCommit, func() errs.Err {
+		return conn.conn.PostCommit(ag)
+	})
+}
+
+// IsCommitted returns the result of IsCommitted of the wrapped connection.
+func (conn *FaultDataConn) IsCommitted() bool {
+	return conn.conn.IsCommitted()
+}
+
+// Rollback calls Rollback of the wrapped connection unless a fault is injected.
+func (conn *FaultDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err {
+	return conn.ds.inject(OpRollback, func() errs.Err {
+		return conn.conn.Rollback(ag)
+	})
+}
+
+// OnTxnFailure calls OnTxnFailure of the wrapped connection.
+func (conn *FaultDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+	conn.ds.delay(OpOnTxnFailure, func() {
+		conn.conn.OnTxnFailure(ag, reports)
+	})
+}
+
+// Close calls Close of the wrapped connection.
+func (conn *FaultDataConn) Close() {
+	conn.ds.delay(OpCloseDataConn, conn.conn.Close)
+}
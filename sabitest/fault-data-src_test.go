@@ -0,0 +1,239 @@
+package sabitest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabitest"
+)
+
+func TestFaultDataSrc(t *testing.T) {
+	t.Run("unwrap", func(t *testing.T) {
+		inner := sabitest.NewFakeDataSrc("a")
+		var ds sabi.DataSrc = sabitest.NewFaultDataSrc("a", inner, 1)
+		w, ok := ds.(sabi.DataSrcWrapper)
+		assert.True(t, ok)
+		assert.Equal(t, w.Unwrap(), sabi.DataSrc(inner))
+	})
+
+	t.Run("no fault", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1)
+		hub := sabi.NewDataHub()
+		hub.Uses("a", ds)
+
+		err := sabi.Txn(hub, useConns("a"))
+		hub.Close()
+		assert.True(t, err.IsOk())
+
+		sabitest.AssertEvents(t, "a Setup", "a CreateDataConn", "a PreCommit", "a Commit",
+			"a PostCommit", "a CloseDataConn", "a CloseDataSrc")
+		assert.Equal(t, ds.Calls(sabitest.OpCommit), 1)
+	})
+
+	t.Run("get wrapped data conn", func(t *testing.T) {
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1)
+		hub := sabi.NewDataHub()
+		hub.Uses("a", ds)
+		defer hub.Close()
+
+		err := sabi.Run(hub, func(hub sabi.DataHub) errs.Err {
+			conn, err := sabi.GetDataConn[*sabitest.FakeDataConn](hub, "a")
+			assert.True(t, err.IsOk())
+			assert.Equal(t, conn.Name(), "a")
+
+			_, err = sabi.GetDataConn[*sabitest.FaultDataConn](hub, "a")
+			assert.True(t, err.IsOk())
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	})
+
+	t.Run("fail on Nth call", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1).
+			Inject(sabitest.OpCommit, sabitest.Fault{NthCall: 2})
+		hub := sabi.NewDataHub()
+		hub.Uses("a", ds)
+		defer hub.Close()
+
+		assert.True(t, sabi.Txn(hub, useConns("a")).IsOk())
+
+		err := sabi.Txn(hub, useConns("a"))
+		assert.True(t, err.IsNotOk())
+		switch r := err.Reason().(type) {
+		case sabi.FailToCommitDataConn:
+			assert.Equal(t, r.Errors[0].Err.Reason(),
+				sabitest.InjectedFault{Name: "a", Op: sabitest.OpCommit, Call: 2})
+		default:
+			assert.Fail(t, err.Error())
+		}
+
+		assert.True(t, sabi.Txn(hub, useConns("a")).IsOk())
+		assert.Equal(t, ds.Calls(sabitest.OpCommit), 3)
+
+		sabitest.AssertEventsInOrder(t, "a Commit", "a Rollback", "a Commit")
+	})
+
+	t.Run("fail with probability", func(t *testing.T) {
+		failures := func(seed int64) []int {
+			ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), seed).
+				Inject(sabitest.OpSetup, sabitest.Fault{Probability: 0.5})
+			a := []int{}
+			for i := 1; i <= 20; i++ {
+				if ds.Setup(nil).IsNotOk() {
+					a = append(a, i)
+				}
+			}
+			return a
+		}
+
+		a := failures(42)
+		assert.Greater(t, len(a), 0)
+		assert.Less(t, len(a), 20)
+		assert.Equal(t, failures(42), a)
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 42).
+			Inject(sabitest.OpSetup, sabitest.Fault{Probability: 1})
+		assert.True(t, ds.Setup(nil).IsNotOk())
+
+		ds = sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 42).
+			Inject(sabitest.OpSetup, sabitest.Fault{Probability: 0})
+		assert.True(t, ds.Setup(nil).IsOk())
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1).
+			Inject(sabitest.OpCloseDataSrc, sabitest.Fault{Latency: 20 * time.Millisecond, NthCall: 1})
+
+		start := time.Now()
+		ds.Close()
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		assert.Equal(t, ds.Calls(sabitest.OpCloseDataSrc), 1)
+		sabitest.AssertEvents(t, "a CloseDataSrc")
+	})
+
+	t.Run("never skip operations which cannot fail", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1).
+			Inject(sabitest.OpCloseDataConn, sabitest.Fault{NthCall: 1}).
+			Inject(sabitest.OpOnTxnFailure, sabitest.Fault{Probability: 1}).
+			Inject(sabitest.OpCloseDataSrc, sabitest.Fault{NthCall: 1, Probability: 1})
+
+		conn, err := ds.CreateDataConn()
+		assert.True(t, err.IsOk())
+		conn.OnTxnFailure(nil, nil)
+		conn.Close()
+		ds.Close()
+
+		sabitest.AssertEvents(t,
+			"a CreateDataConn", "a OnTxnFailure", "a CloseDataConn", "a CloseDataSrc")
+	})
+
+	t.Run("fail on create data conn after call", func(t *testing.T) {
+		sabitest.ResetEvents()
+
+		ds := sabitest.NewFaultDataSrc("a", sabitest.NewFakeDataSrc("a"), 1).
+			Inject(sabitest.OpCreateDataConn, sabitest.Fault{NthCall: 1, AfterCall: true})
+
+		conn, err := ds.CreateDataConn()
+		assert.Nil(t, conn)
+		assert.Equal(t, err.Reason(), sabitest.InjectedFault{
+			Name: "a", Op: sabitest.OpCreateDataConn, Call: 1, AfterCall: true})
+		sabitest.AssertEvents(t, "a CreateDataConn", "a CloseDataConn")
+	})
+
+	t.Run("recovery branches", func(t *testing.T) {
+		type testCase struct {
+			name     string
+			faults   map[string]map[sabitest.Op]sabitest.Fault
+			expected map[string]sabi.TxnFailureRecovery
+		}
+		testCases := []testCase{
+			{
+				name: "pre-commit failure",
+				faults: map[string]map[sabitest.Op]sabitest.Fault{
+					"b": {sabitest.OpPreCommit: {NthCall: 1}},
+				},
+				expected: map[string]sabi.TxnFailureRecovery{
+					"a": sabi.RerunLogicAndCommit,
+					"b": sabi.ResolveCauseThenRerunLogicAndCommit,
+				},
+			},
+			{
+				name: "commit failure",
+				faults: map[string]map[sabitest.Op]sabitest.Fault{
+					"b": {sabitest.OpCommit: {NthCall: 1}},
+				},
+				expected: map[string]sabi.TxnFailureRecovery{
+					"a": sabi.NoActionRequired,
+					"b": sabi.ResolveCauseThenRerunLogicAndCommit,
+				},
+			},
+			{
+				name: "commit failure and rollback failure",
+				faults: map[string]map[sabitest.Op]sabitest.Fault{
+					"b": {sabitest.OpCommit: {NthCall: 1}, sabitest.OpRollback: {NthCall: 1}},
+				},
+				expected: map[string]sabi.TxnFailureRecovery{
+					"a": sabi.NoActionRequired,
+					"b": sabi.ResolveCauseAndInconsistency,
+				},
+			},
+			{
+				name: "crash after commit",
+				faults: map[string]map[sabitest.Op]sabitest.Fault{
+					"b": {sabitest.OpCommit: sabitest.CrashAfterCommit(1)},
+				},
+				expected: map[string]sabi.TxnFailureRecovery{
+					"a": sabi.NoActionRequired,
+					"b": sabi.InvestigateBecauseImpossible,
+				},
+			},
+			{
+				name: "post-commit failure",
+				faults: map[string]map[sabitest.Op]sabitest.Fault{
+					"b": {sabitest.OpPostCommit: {NthCall: 1}},
+				},
+				expected: map[string]sabi.TxnFailureRecovery{
+					"a": sabi.NoActionRequired,
+					"b": sabi.ResolveCauseThenRerunPostCommit,
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				fake := sabitest.NewFakeDataSrc("a")
+				hub := sabi.NewDataHub()
+				for _, name := range []string{"a", "b"} {
+					inner := fake
+					if name != "a" {
+						inner = sabitest.NewFakeDataSrc(name)
+					}
+					ds := sabitest.NewFaultDataSrc(name, inner, 1)
+					for op, fault := range tc.faults[name] {
+						ds.Inject(op, fault)
+					}
+					hub.Uses(name, ds)
+				}
+				defer hub.Close()
+
+				assert.True(t, sabi.Txn(hub, useConns("a", "b")).IsNotOk())
+
+				reports := fake.Reports()
+				for name, recovery := range tc.expected {
+					sabitest.AssertRecovery(t, reports, name, recovery)
+				}
+			})
+		}
+	})
+}
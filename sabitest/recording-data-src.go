@@ -0,0 +1,299 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+// GoldenUpdateEnv is the name of the environment variable which makes AssertGolden write the
+// actual content to the golden file instead of comparing, if it is set to a non-empty value.
+const GoldenUpdateEnv = "SABITEST_UPDATE_GOLDEN"
+
+// Call is a record of a call of a lifecycle method of a DataConn.
+type Call struct {
+	// Name is the name of the recording data source.
+	Name string `json:"name"`
+	// Op is the called operation.
+	Op Op `json:"op"`
+	// Error is the string representation of the reason of the error returned by the call, or
+	// empty if the call succeeded.
+	Error string `json:"error,omitempty"`
+	// Reports are the string representations of the TxnFailureReports passed to OnTxnFailure.
+	Reports []string `json:"reports,omitempty"`
+}
+
+// TxnTrace is the sequence of the calls recorded in a transaction.
+type TxnTrace struct {
+	// Txn is the 1-based number of the transaction.
+	Txn int `json:"txn"`
+	// Calls are the calls in the order in which they ended.
+	Calls []Call `json:"calls"`
+}
+
+// Recorder records the calls of the DataConns created by RecordingDataSrcs sharing it.
+//
+// A transaction is considered to begin when a DataConn is created while no DataConn of this
+// recorder is open, and to end when all of them are closed. This matches a Run or Txn as long as
+// a recorder is used by one DataHub at a time.
+// The recorded trace contains no timings or addresses, so it is deterministic as far as the
+// logic is.
+type Recorder struct {
+	mutex  sync.Mutex
+	traces []TxnTrace
+	open   int
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (rec *Recorder) record(call Call) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if call.Op == OpCreateDataConn && rec.open == 0 {
+		rec.traces = append(rec.traces, TxnTrace{Txn: len(rec.traces) + 1})
+	}
+	if len(rec.traces) == 0 {
+		rec.traces = append(rec.traces, TxnTrace{Txn: 1})
+	}
+	switch call.Op {
+	case OpCreateDataConn:
+		if len(call.Error) == 0 {
+			rec.open++
+		}
+	case OpCloseDataConn:
+		rec.open--
+	}
+
+	trace := &rec.traces[len(rec.traces)-1]
+	trace.Calls = append(trace.Calls, call)
+}
+
+// Traces returns a copy of the recorded traces.
+func (rec *Recorder) Traces() []TxnTrace {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	traces := make([]TxnTrace, len(rec.traces))
+	for i, trace := range rec.traces {
+		traces[i] = TxnTrace{Txn: trace.Txn, Calls: append([]Call(nil), trace.Calls...)}
+	}
+	return traces
+}
+
+// Reset clears the recorded traces.
+func (rec *Recorder) Reset() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.traces = nil
+	rec.open = 0
+}
+
+// Text returns the recorded traces in a text format, which has a "txn N" line for each
+// transaction followed by an indented line for each call.
+func (rec *Recorder) Text() string {
+	var b strings.Builder
+	for _, trace := range rec.Traces() {
+		fmt.Fprintf(&b, "txn %d\n", trace.Txn)
+		for _, call := range trace.Calls {
+			b.WriteString("  ")
+			b.WriteString(call.Name)
+			b.WriteByte(' ')
+			b.WriteString(string(call.Op))
+			if len(call.Error) > 0 {
+				b.WriteString(" error: ")
+				b.WriteString(call.Error)
+			}
+			b.WriteByte('\n')
+			for _, report := range call.Reports {
+				b.WriteString("    ")
+				b.WriteString(report)
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return b.String()
+}
+
+// JSON returns the recorded traces in an indented JSON format.
+func (rec *Recorder) JSON() []byte {
+	traces := rec.Traces()
+	if traces == nil {
+		traces = []TxnTrace{}
+	}
+	b, _ := json.MarshalIndent(traces, "", "  ")
+	return append(b, '\n')
+}
+
+// AssertGolden compares the actual content with the content of the golden file at the path,
+// and reports an error to t with the first different line if they are not equal.
+// If the environment variable named GoldenUpdateEnv is set, this function writes the actual
+// content to the golden file instead, creating its directory if needed.
+func AssertGolden(t testing.TB, path string, actual []byte) bool {
+	t.Helper()
+
+	if len(os.Getenv(GoldenUpdateEnv)) > 0 {
+		if e := os.MkdirAll(filepath.Dir(path), 0o755); e != nil {
+			t.Errorf("fail to create the directory of golden file %q: %v", path, e)
+			return false
+		}
+		if e := os.WriteFile(path, actual, 0o644); e != nil {
+			t.Errorf("fail to write golden file %q: %v", path, e)
+			return false
+		}
+		return true
+	}
+
+	expected, e := os.ReadFile(path)
+	if e != nil {
+		t.Errorf("fail to read golden file %q (set %s=1 to create it): %v", path, GoldenUpdateEnv, e)
+		return false
+	}
+	if bytes.Equal(actual, expected) {
+		return true
+	}
+
+	expLines := strings.Split(string(expected), "\n")
+	actLines := strings.Split(string(actual), "\n")
+	i := 0
+	for i < len(expLines) && i < len(actLines) && expLines[i] == actLines[i] {
+		i++
+	}
+	line := func(a []string) string {
+		if i < len(a) {
+			return fmt.Sprintf("%q", a[i])
+		}
+		return "(EOF)"
+	}
+	t.Errorf("content differs from golden file %q at line %d:\nexpected: %s\nactual  : %s",
+		path, i+1, line(expLines), line(actLines))
+	return false
+}
+
+// RecordingDataSrc is a DataSrc which wraps another DataSrc and records the calls of the
+// lifecycle methods of its DataConns to a Recorder.
+//
+// This data source implements sabi.DataSrcWrapper, so sabi.Health checks the wrapped data
+// source, and the DataConns created by it implement sabi.DataConnWrapper, so sabi.GetDataConn
+// returns the wrapped connections to DataAcc implementations.
+type RecordingDataSrc struct {
+	name string
+	ds   sabi.DataSrc
+	rec  *Recorder
+}
+
+// NewRecordingDataSrc creates a new RecordingDataSrc which wraps the data source ds and records
+// calls with the name to the recorder rec.
+func NewRecordingDataSrc(name string, ds sabi.DataSrc, rec *Recorder) *RecordingDataSrc {
+	return &RecordingDataSrc{name: name, ds: ds, rec: rec}
+}
+
+// Unwrap returns the wrapped DataSrc.
+func (ds *RecordingDataSrc) Unwrap() sabi.DataSrc {
+	return ds.ds
+}
+
+// Setup calls Setup of the wrapped data source.
+func (ds *RecordingDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err {
+	return ds.ds.Setup(ag)
+}
+
+// Close calls Close of the wrapped data source.
+func (ds *RecordingDataSrc) Close() {
+	ds.ds.Close()
+}
+
+// CreateDataConn calls CreateDataConn of the wrapped data source, records the call, and returns
+// a RecordingDataConn wrapping the created connection.
+func (ds *RecordingDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	conn, err := ds.ds.CreateDataConn()
+	ds.rec.record(Call{Name: ds.name, Op: OpCreateDataConn, Error: reasonString(err)})
+	if err.IsNotOk() {
+		return nil, err
+	}
+	return &RecordingDataConn{ds: ds, conn: conn}, errs.Ok()
+}
+
+func reasonString(err errs.Err) string {
+	if err.IsOk() {
+		return ""
+	}
+	return fmt.Sprintf("%v", err.Reason())
+}
+
+// RecordingDataConn is a DataConn created by RecordingDataSrc, which wraps a DataConn of the
+// wrapped data source and records the calls of its lifecycle methods.
+type RecordingDataConn struct {
+	ds   *RecordingDataSrc
+	conn sabi.DataConn
+}
+
+// Unwrap returns the wrapped DataConn.
+func (conn *RecordingDataConn) Unwrap() sabi.DataConn {
+	return conn.conn
+}
+
+func (conn *RecordingDataConn) record(op Op, err errs.Err) errs.Err {
+	conn.ds.rec.record(Call{Name: conn.ds.name, Op: op, Error: reasonString(err)})
+	return err
+}
+
+// PreCommit calls PreCommit of the wrapped connection and records the call.
+func (conn *RecordingDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.record(OpPreCommit, conn.conn.PreCommit(ag))
+}
+
+// Commit calls Commit of the wrapped connection and records the call.
+func (conn *RecordingDataConn) Commit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.record(OpCommit, conn.conn.Commit(ag))
+}
+
+// PostCommit calls PostCommit of the wrapped connection and records the call.
+func (conn *RecordingDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err {
+	return conn.record(OpPostCommit, conn.conn.PostCommit(ag))
+}
+
+// IsCommitted returns the result of IsCommitted of the wrapped connection. This call is not
+// recorded.
+func (conn *RecordingDataConn) IsCommitted() bool {
+	return conn.conn.IsCommitted()
+}
+
+// Rollback calls Rollback of the wrapped connection and records the call.
+func (conn *RecordingDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err {
+	return conn.record(OpRollback, conn.conn.Rollback(ag))
+}
+
+// OnTxnFailure calls OnTxnFailure of the wrapped connection and records the call with the cause
+// and rollback states of the reports.
+func (conn *RecordingDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+	conn.conn.OnTxnFailure(ag, reports)
+
+	a := make([]string, len(reports))
+	for i, report := range reports {
+		a[i] = report.DataConnName + " " + report.Cause.State.String() + " " +
+			report.Rollback.State.String()
+	}
+	conn.ds.rec.record(Call{Name: conn.ds.name, Op: OpOnTxnFailure, Reports: a})
+}
+
+// Close calls Close of the wrapped connection and records the call.
+func (conn *RecordingDataConn) Close() {
+	conn.conn.Close()
+	conn.ds.rec.record(Call{Name: conn.ds.name, Op: OpCloseDataConn})
+}
@@ -0,0 +1,133 @@
+package sabitest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/sabitest"
+)
+
+func newRecordingHub(rec *sabitest.Recorder) sabi.DataHub {
+	hub := sabi.NewDataHub()
+	hub.Uses("a", sabitest.NewRecordingDataSrc("a", sabitest.NewFakeDataSrc("a"), rec))
+	hub.Uses("b", sabitest.NewRecordingDataSrc("b",
+		sabitest.NewFakeDataSrc("b").On(sabitest.OpCommit, sabitest.Fail("boom")), rec))
+	return hub
+}
+
+func TestRecordingDataSrc(t *testing.T) {
+	t.Run("unwrap", func(t *testing.T) {
+		inner := sabitest.NewFakeDataSrc("a")
+		var ds sabi.DataSrc = sabitest.NewRecordingDataSrc("a", inner, sabitest.NewRecorder())
+		w, ok := ds.(sabi.DataSrcWrapper)
+		assert.True(t, ok)
+		assert.Equal(t, w.Unwrap(), sabi.DataSrc(inner))
+	})
+
+	t.Run("record traces", func(t *testing.T) {
+		rec := sabitest.NewRecorder()
+		hub := newRecordingHub(rec)
+		defer hub.Close()
+
+		assert.True(t, sabi.Txn(hub, useConns("a")).IsOk())
+		assert.True(t, sabi.Txn(hub, useConns("a", "b")).IsNotOk())
+
+		traces := rec.Traces()
+		assert.Len(t, traces, 2)
+		assert.Equal(t, traces[0], sabitest.TxnTrace{Txn: 1, Calls: []sabitest.Call{
+			{Name: "a", Op: sabitest.OpCreateDataConn},
+			{Name: "a", Op: sabitest.OpPreCommit},
+			{Name: "a", Op: sabitest.OpCommit},
+			{Name: "a", Op: sabitest.OpPostCommit},
+			{Name: "a", Op: sabitest.OpCloseDataConn},
+		}})
+		assert.Equal(t, traces[1].Txn, 2)
+		assert.Contains(t, traces[1].Calls,
+			sabitest.Call{Name: "b", Op: sabitest.OpCommit, Error: "boom"})
+
+		rec.Reset()
+		assert.Len(t, rec.Traces(), 0)
+		assert.Equal(t, rec.Text(), "")
+		assert.Equal(t, string(rec.JSON()), "[]\n")
+	})
+
+	t.Run("get wrapped data conn", func(t *testing.T) {
+		rec := sabitest.NewRecorder()
+		hub := newRecordingHub(rec)
+		defer hub.Close()
+
+		err := sabi.Run(hub, func(hub sabi.DataHub) errs.Err {
+			conn, err := sabi.GetDataConn[*sabitest.FakeDataConn](hub, "a")
+			assert.True(t, err.IsOk())
+			assert.Equal(t, conn.Name(), "a")
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	})
+
+	t.Run("fail to create data conn", func(t *testing.T) {
+		rec := sabitest.NewRecorder()
+		ds := sabitest.NewRecordingDataSrc("a",
+			sabitest.NewFakeDataSrc("a").On(sabitest.OpCreateDataConn, sabitest.Fail("boom")), rec)
+
+		conn, err := ds.CreateDataConn()
+		assert.Nil(t, conn)
+		assert.True(t, err.IsNotOk())
+		assert.Equal(t, rec.Traces(), []sabitest.TxnTrace{{Txn: 1, Calls: []sabitest.Call{
+			{Name: "a", Op: sabitest.OpCreateDataConn, Error: "boom"},
+		}}})
+	})
+
+	t.Run("golden files", func(t *testing.T) {
+		rec := sabitest.NewRecorder()
+		hub := newRecordingHub(rec)
+		defer hub.Close()
+
+		assert.True(t, sabi.Txn(hub, useConns("a")).IsOk())
+		assert.True(t, sabi.Txn(hub, useConns("a", "b")).IsNotOk())
+
+		sabitest.AssertGolden(t, "testdata/recording.txt", []byte(rec.Text()))
+		sabitest.AssertGolden(t, "testdata/recording.json", rec.JSON())
+	})
+}
+
+func TestAssertGolden(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "golden.txt")
+
+	t.Run("no golden file", func(t *testing.T) {
+		rt := &recordingT{}
+		assert.False(t, sabitest.AssertGolden(rt, path, []byte("a\n")))
+		assert.Len(t, rt.errors, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Setenv(sabitest.GoldenUpdateEnv, "1")
+
+		rt := &recordingT{}
+		assert.True(t, sabitest.AssertGolden(rt, path, []byte("a\nb\n")))
+		assert.Len(t, rt.errors, 0)
+
+		b, e := os.ReadFile(path)
+		assert.Nil(t, e)
+		assert.Equal(t, string(b), "a\nb\n")
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		rt := &recordingT{}
+		assert.True(t, sabitest.AssertGolden(rt, path, []byte("a\nb\n")))
+		assert.Len(t, rt.errors, 0)
+
+		assert.False(t, sabitest.AssertGolden(rt, path, []byte("a\nc\n")))
+		assert.False(t, sabitest.AssertGolden(rt, path, []byte("a\n")))
+		assert.Len(t, rt.errors, 2)
+		assert.Equal(t, rt.errors[0], "content differs from golden file \""+path+
+			"\" at line 2:\nexpected: \"b\"\nactual  : \"c\"")
+		assert.Equal(t, rt.errors[1], "content differs from golden file \""+path+
+			"\" at line 2:\nexpected: \"b\"\nactual  : \"\"")
+	})
+}
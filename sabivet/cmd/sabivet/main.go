@@ -0,0 +1,21 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// Sabivet reports mistakes in the usage of the sabi framework. It is intended to be run by
+// go vet:
+//
+//	go vet -vettool=$(which sabivet) ./...
+//
+// See the package github.com/sttk/sabi/sabivet for the reported mistakes.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/sttk/sabi/sabivet"
+)
+
+func main() {
+	unitchecker.Main(sabivet.Analyzer)
+}
@@ -0,0 +1,552 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// Package sabivet provides a static analyzer which reports mistakes in the usage of the sabi
+// framework.
+//
+// The analyzer reports:
+//   - Run and Txn calls whose DataHub cannot be cast to the parameter type of the logic, which
+//     would fail at runtime with FailToCastDataHub,
+//   - GetDataConn calls whose type argument is not the type of any DataConn created by the data
+//     sources registered with the name in the same package, which would fail at runtime with
+//     FailToCastDataConn,
+//   - global Uses calls after Setup in the same function, which are ignored,
+//   - Setup and SetupWithOrder calls whose results are ignored.
+//
+// The types of DataHubs and DataConns are traced only through local variables, composite
+// literals and functions declared in the analyzed packages, and calls which cannot be traced are
+// not reported. The analyzer can be run with go vet by the sabivet command:
+//
+//	go install github.com/sttk/sabi/sabivet/cmd/sabivet@latest
+//	go vet -vettool=$(which sabivet) ./...
+//
+// This package and the sabivet command are in their own module, so that golang.org/x/tools is not
+// required by the modules which use only the sabi framework.
+package sabivet
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const sabiPath = "github.com/sttk/sabi"
+
+// maxTraceDepth is the maximum depth of variables and function calls traced to determine the
+// concrete types of an expression.
+const maxTraceDepth = 8
+
+// Analyzer is the analyzer which reports mistakes in the usage of the sabi framework.
+var Analyzer = &analysis.Analyzer{
+	Name:      "sabivet",
+	Doc:       "report mistakes in the usage of the sabi framework",
+	URL:       "https://pkg.go.dev/github.com/sttk/sabi/sabivet",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	FactTypes: []analysis.Fact{new(dataConnTypes)},
+	Run:       run,
+}
+
+// dataConnTypes is a fact attached to a CreateDataConn method, which holds the type strings of
+// the DataConns the method can return.
+type dataConnTypes struct {
+	Types []string
+}
+
+// AFact marks dataConnTypes as an analysis.Fact.
+func (*dataConnTypes) AFact() {}
+
+func (f *dataConnTypes) String() string {
+	return "dataConnTypes(" + strings.Join(f.Types, ", ") + ")"
+}
+
+type checker struct {
+	pass    *analysis.Pass
+	decls   map[*types.Func]*ast.FuncDecl
+	assigns map[*types.Var][]ast.Expr
+	// registered maps the names of data sources to the type strings of their DataConns.
+	// A nil value means that the types are unknown.
+	registered map[string]map[string]bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{
+		pass:       pass,
+		decls:      make(map[*types.Func]*ast.FuncDecl),
+		assigns:    make(map[*types.Var][]ast.Expr),
+		registered: make(map[string]map[string]bool),
+	}
+	c.collectDecls()
+	c.exportDataConnTypes()
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		fn := callee(pass.TypesInfo, call)
+		if fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == sabiPath && fn.Name() == "Uses" &&
+			len(call.Args) == 2 {
+			c.register(call)
+		}
+	})
+
+	insp.Preorder([]ast.Node{
+		(*ast.CallExpr)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.DeferStmt)(nil),
+		(*ast.GoStmt)(nil),
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			fn := callee(pass.TypesInfo, n)
+			if fn == nil || !isSabiFunc(fn) {
+				return
+			}
+			switch fn.Name() {
+			case "Run", "Txn":
+				c.checkRunOrTxn(n, fn.Name())
+			case "GetDataConn":
+				c.checkGetDataConn(n)
+			}
+		case *ast.ExprStmt:
+			c.checkIgnoredSetup(n.X)
+		case *ast.DeferStmt:
+			c.checkIgnoredSetup(n.Call)
+		case *ast.GoStmt:
+			c.checkIgnoredSetup(n.Call)
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i, rhs := range n.Rhs {
+					if isBlank(n.Lhs[i]) {
+						c.checkIgnoredSetup(rhs)
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				c.checkUsesAfterSetup(n.Body)
+			}
+		case *ast.FuncLit:
+			c.checkUsesAfterSetup(n.Body)
+		}
+	})
+
+	return nil, nil
+}
+
+func callee(info *types.Info, call *ast.CallExpr) *types.Func {
+	fn, _ := typeutil.Callee(info, call).(*types.Func)
+	return fn
+}
+
+func isSabiFunc(fn *types.Func, names ...string) bool {
+	if fn.Pkg() == nil || fn.Pkg().Path() != sabiPath {
+		return false
+	}
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return false
+	}
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if fn.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(expr ast.Expr) bool {
+	ident, ok := ast.Unparen(expr).(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
+func typeArgs(info *types.Info, fun ast.Expr) *types.TypeList {
+	fun = ast.Unparen(fun)
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	var ident *ast.Ident
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	default:
+		return nil
+	}
+	inst, ok := info.Instances[ident]
+	if !ok {
+		return nil
+	}
+	return inst.TypeArgs
+}
+
+func (c *checker) collectDecls() {
+	info := c.pass.TypesInfo
+	for _, f := range c.pass.Files {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				if fn, ok := info.Defs[fd.Name].(*types.Func); ok {
+					c.decls[fn] = fd
+				}
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if len(n.Lhs) != len(n.Rhs) {
+					for _, lhs := range n.Lhs {
+						c.addAssign(lhs, nil)
+					}
+					return true
+				}
+				for i, lhs := range n.Lhs {
+					c.addAssign(lhs, n.Rhs[i])
+				}
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					if i < len(n.Values) && len(n.Names) == len(n.Values) {
+						c.addAssign(name, n.Values[i])
+					} else {
+						c.addAssign(name, nil)
+					}
+				}
+			case *ast.FuncType:
+				// Parameters and named results have values not traceable in the function body.
+				for _, list := range []*ast.FieldList{n.Params, n.Results} {
+					if list == nil {
+						continue
+					}
+					for _, field := range list.List {
+						for _, name := range field.Names {
+							c.addAssign(name, nil)
+						}
+					}
+				}
+			case *ast.RangeStmt:
+				if n.Key != nil {
+					c.addAssign(n.Key, nil)
+				}
+				if n.Value != nil {
+					c.addAssign(n.Value, nil)
+				}
+			case *ast.UnaryExpr:
+				// Taking the address of a variable makes its assignments untraceable.
+				if ident, ok := ast.Unparen(n.X).(*ast.Ident); ok && n.Op == token.AND {
+					c.addAssign(ident, nil)
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (c *checker) addAssign(lhs ast.Expr, rhs ast.Expr) {
+	ident, ok := ast.Unparen(lhs).(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj := c.pass.TypesInfo.ObjectOf(ident)
+	v, ok := obj.(*types.Var)
+	if !ok || v.IsField() {
+		return
+	}
+	c.assigns[v] = append(c.assigns[v], rhs)
+}
+
+// concreteTypes returns the possible dynamic types of the expression, or nil if they cannot be
+// determined.
+func (c *checker) concreteTypes(expr ast.Expr, depth int) []types.Type {
+	if depth > maxTraceDepth {
+		return nil
+	}
+	info := c.pass.TypesInfo
+	t := info.TypeOf(expr)
+	if t == nil {
+		return nil
+	}
+	if !types.IsInterface(t) {
+		return []types.Type{t}
+	}
+
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		v, ok := info.ObjectOf(e).(*types.Var)
+		if !ok || v.Pkg() != c.pass.Pkg || v.Parent() == v.Pkg().Scope() {
+			return nil
+		}
+		rhss, ok := c.assigns[v]
+		if !ok {
+			return nil
+		}
+		var ts []types.Type
+		for _, rhs := range rhss {
+			if rhs == nil {
+				return nil
+			}
+			rts := c.concreteTypes(rhs, depth+1)
+			if rts == nil {
+				return nil
+			}
+			ts = append(ts, rts...)
+		}
+		return ts
+	case *ast.CallExpr:
+		fn := callee(info, e)
+		if fn == nil {
+			return nil
+		}
+		if isSabiFunc(fn, "NewDataHub", "NewDataHubWithCommitOrder") {
+			// The DataHub implementation of sabi has no exported methods other than the ones of
+			// the DataHub interface.
+			return []types.Type{t}
+		}
+		return c.returnTypes(fn, depth)
+	}
+	return nil
+}
+
+// returnTypes returns the possible dynamic types of the first result of the function declared
+// in the analyzed package, or nil if they cannot be determined.
+func (c *checker) returnTypes(fn *types.Func, depth int) []types.Type {
+	fd, ok := c.decls[fn.Origin()]
+	if !ok || fd.Body == nil || fd.Type.Results == nil {
+		return nil
+	}
+	var ts []types.Type
+	known := true
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if !known {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) == 0 {
+				known = false
+				return false
+			}
+			if isNil(c.pass.TypesInfo, n.Results[0]) {
+				return false
+			}
+			rts := c.concreteTypes(n.Results[0], depth+1)
+			if rts == nil {
+				known = false
+				return false
+			}
+			for _, t := range rts {
+				// The type arguments of generic functions and methods are not traced.
+				if hasTypeParam(t) {
+					known = false
+					return false
+				}
+			}
+			ts = append(ts, rts...)
+		}
+		return true
+	})
+	if !known {
+		return nil
+	}
+	return ts
+}
+
+func hasTypeParam(t types.Type) bool {
+	switch t := types.Unalias(t).(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return hasTypeParam(t.Elem())
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if hasTypeParam(t.TypeArgs().At(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNil(info *types.Info, expr ast.Expr) bool {
+	tv, ok := info.Types[expr]
+	return ok && tv.IsNil()
+}
+
+func assignable(v, t types.Type) bool {
+	if iface, ok := t.Underlying().(*types.Interface); ok {
+		return types.Implements(v, iface)
+	}
+	return types.Identical(v, t)
+}
+
+func (c *checker) checkRunOrTxn(call *ast.CallExpr, name string) {
+	targs := typeArgs(c.pass.TypesInfo, call.Fun)
+	if targs == nil || targs.Len() == 0 || len(call.Args) < 2 {
+		return
+	}
+	d := targs.At(0)
+
+	for _, t := range c.concreteTypes(call.Args[0], 0) {
+		if !assignable(t, d) {
+			c.pass.ReportRangef(call.Args[0],
+				"%s fails with FailToCastDataHub: DataHub of type %s cannot be cast to %s",
+				name, typeString(c.pass.Pkg, t), typeString(c.pass.Pkg, d))
+			return
+		}
+	}
+}
+
+func typeString(pkg *types.Package, t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(pkg))
+}
+
+// dataConnTypesOf returns the type strings of the DataConns created by the data sources of the
+// types, or nil if they cannot be determined.
+func (c *checker) dataConnTypesOf(dsTypes []types.Type) map[string]bool {
+	if dsTypes == nil {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, t := range dsTypes {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, c.pass.Pkg, "CreateDataConn")
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			return nil
+		}
+		var fact dataConnTypes
+		if fn.Pkg() == c.pass.Pkg {
+			ts := c.returnTypes(fn, 0)
+			if ts == nil {
+				return nil
+			}
+			for _, ct := range ts {
+				set[types.TypeString(ct, nil)] = true
+			}
+		} else if c.pass.ImportObjectFact(fn, &fact) {
+			for _, s := range fact.Types {
+				set[s] = true
+			}
+		} else {
+			return nil
+		}
+	}
+	return set
+}
+
+func (c *checker) exportDataConnTypes() {
+	for fn, fd := range c.decls {
+		if fn.Name() != "CreateDataConn" || fd.Recv == nil {
+			continue
+		}
+		ts := c.returnTypes(fn, 0)
+		if ts == nil {
+			continue
+		}
+		fact := &dataConnTypes{}
+		for _, t := range ts {
+			fact.Types = append(fact.Types, types.TypeString(t, nil))
+		}
+		c.pass.ExportObjectFact(fn, fact)
+	}
+}
+
+func (c *checker) register(call *ast.CallExpr) {
+	tv, ok := c.pass.TypesInfo.Types[call.Args[0]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	name := constant.StringVal(tv.Value)
+
+	set, seen := c.registered[name]
+	if seen && set == nil {
+		return
+	}
+	added := c.dataConnTypesOf(c.concreteTypes(call.Args[1], 0))
+	if added == nil {
+		c.registered[name] = nil
+		return
+	}
+	if set == nil {
+		set = make(map[string]bool)
+	}
+	for s := range added {
+		set[s] = true
+	}
+	c.registered[name] = set
+}
+
+func (c *checker) checkGetDataConn(call *ast.CallExpr) {
+	targs := typeArgs(c.pass.TypesInfo, call.Fun)
+	if targs == nil || targs.Len() == 0 || len(call.Args) < 2 {
+		return
+	}
+	ct := targs.At(0)
+	if types.IsInterface(ct) {
+		return
+	}
+
+	tv, ok := c.pass.TypesInfo.Types[call.Args[1]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	name := constant.StringVal(tv.Value)
+
+	set := c.registered[name]
+	if set == nil || set[types.TypeString(ct, nil)] {
+		return
+	}
+	c.pass.ReportRangef(call,
+		"GetDataConn fails with FailToCastDataConn: no data source registered as %q creates %s",
+		name, typeString(c.pass.Pkg, ct))
+}
+
+func (c *checker) checkIgnoredSetup(expr ast.Expr) {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	fn := callee(c.pass.TypesInfo, call)
+	if fn == nil || !isSabiFunc(fn, "Setup", "SetupWithOrder") {
+		return
+	}
+	c.pass.ReportRangef(call, "the error returned by %s is ignored", fn.Name())
+}
+
+func (c *checker) checkUsesAfterSetup(body *ast.BlockStmt) {
+	setup := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			fn := callee(c.pass.TypesInfo, n)
+			if fn == nil {
+				return true
+			}
+			switch {
+			case isSabiFunc(fn, "Setup", "SetupWithOrder"):
+				setup = true
+			case setup && isSabiFunc(fn, "Uses"):
+				c.pass.ReportRangef(n,
+					"Uses is called after Setup, so the data source is not registered")
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,13 @@
+package sabivet_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/sttk/sabi/sabivet"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), sabivet.Analyzer, "./app", "./dsrc")
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+
+	"vettest/dsrc"
+)
+
+type BarDataSrc struct{}
+
+func (ds *BarDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *BarDataSrc) Close()                             {}
+func (ds *BarDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) { // want CreateDataConn:`dataConnTypes\(\*vettest/app.BarDataConn\)`
+	return newBarDataConn(), errs.Ok()
+}
+
+type BarDataConn struct{}
+
+func newBarDataConn() sabi.DataConn {
+	return &BarDataConn{}
+}
+
+func (conn *BarDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err  { return errs.Ok() }
+func (conn *BarDataConn) Commit(ag *sabi.AsyncGroup) errs.Err     { return errs.Ok() }
+func (conn *BarDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *BarDataConn) IsCommitted() bool                       { return false }
+func (conn *BarDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err   { return errs.Ok() }
+func (conn *BarDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+func (conn *BarDataConn) Close() {}
+
+type MyData interface {
+	GetText() (string, errs.Err)
+}
+
+type OtherData interface {
+	Count() int
+}
+
+type GettingDataAcc struct {
+	sabi.DataAcc
+}
+
+func (da *GettingDataAcc) GetText() (string, errs.Err) {
+	_, err := sabi.GetDataConn[*BarDataConn](da, "bar")
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[*dsrc.FooDataConn](da, "foo")
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[*dsrc.FooDataConn](da, "bar") // want `GetDataConn fails with FailToCastDataConn: no data source registered as "bar" creates \*vettest/dsrc.FooDataConn`
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[*BarDataConn](da, "foo") // want `no data source registered as "foo" creates \*BarDataConn`
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[*BarDataConn](da, "opaque")
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[*BarDataConn](da, "unknown")
+	if !err.IsOk() {
+		return "", err
+	}
+	_, err = sabi.GetDataConn[sabi.DataConn](da, "foo")
+	return "text", err
+}
+
+type MyDataHub struct {
+	sabi.DataHub
+	*GettingDataAcc
+}
+
+func NewMyDataHub() sabi.DataHub {
+	hub := sabi.NewDataHub()
+	return MyDataHub{DataHub: hub, GettingDataAcc: &GettingDataAcc{DataAcc: hub}}
+}
+
+func MyLogic(data MyData) errs.Err {
+	_, err := data.GetText()
+	return err
+}
+
+func OtherLogic(data OtherData) errs.Err {
+	return errs.Ok()
+}
+
+func init() {
+	sabi.Uses("foo", &dsrc.FooDataSrc{})
+	sabi.Uses("opaque", &dsrc.OpaqueDataSrc{})
+}
+
+func main() {
+	sabi.Setup() // want `the error returned by Setup is ignored`
+	defer sabi.Shutdown()
+
+	_ = sabi.SetupWithOrder("foo") // want `the error returned by SetupWithOrder is ignored`
+
+	if err := sabi.Setup(); !err.IsOk() {
+		return
+	}
+
+	sabi.Uses("late", &BarDataSrc{}) // want `Uses is called after Setup, so the data source is not registered`
+
+	hub := NewMyDataHub()
+	defer hub.Close()
+	hub.Uses("bar", &BarDataSrc{})
+
+	_ = sabi.Txn(hub, MyLogic)
+	_ = sabi.Run(hub, OtherLogic)            // want `Run fails with FailToCastDataHub: DataHub of type MyDataHub cannot be cast to OtherData`
+	_ = sabi.Txn[OtherData](hub, OtherLogic) // want `Txn fails with FailToCastDataHub`
+
+	plain := sabi.NewDataHub()
+	_ = sabi.Run(plain, MyLogic) // want `DataHub of type github.com/sttk/sabi.DataHub cannot be cast to MyData`
+
+	_ = sabi.Run(MyDataHub{}, MyLogic)
+	_ = sabi.Run(&MyDataHub{}, MyLogic)
+	_ = sabi.Run(hubOf(true), MyLogic)
+
+	var reassigned sabi.DataHub = NewMyDataHub()
+	reassigned = sabi.NewDataHub()
+	_ = sabi.Run(reassigned, MyLogic) // want `cannot be cast to MyData`
+
+	func() {
+		sabi.Uses("closure", &BarDataSrc{})
+	}()
+}
+
+func hubOf(b bool) sabi.DataHub {
+	if b {
+		return NewMyDataHub()
+	}
+	return nil
+}
+
+func runWith(hub sabi.DataHub) {
+	_ = sabi.Run(hub, OtherLogic)
+}
+
+type GenericDataSrc[T any] struct{}
+
+func (ds *GenericDataSrc[T]) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *GenericDataSrc[T]) Close()                             {}
+func (ds *GenericDataSrc[T]) CreateDataConn() (sabi.DataConn, errs.Err) {
+	return &GenericDataConn[T]{}, errs.Ok()
+}
+
+type GenericDataConn[T any] struct{}
+
+func (conn *GenericDataConn[T]) PreCommit(ag *sabi.AsyncGroup) errs.Err  { return errs.Ok() }
+func (conn *GenericDataConn[T]) Commit(ag *sabi.AsyncGroup) errs.Err     { return errs.Ok() }
+func (conn *GenericDataConn[T]) PostCommit(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *GenericDataConn[T]) IsCommitted() bool                       { return false }
+func (conn *GenericDataConn[T]) Rollback(ag *sabi.AsyncGroup) errs.Err   { return errs.Ok() }
+func (conn *GenericDataConn[T]) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+func (conn *GenericDataConn[T]) Close() {}
+
+func useGeneric(hub sabi.DataHub) {
+	hub.Uses("generic", &GenericDataSrc[int]{})
+	_, _ = sabi.GetDataConn[*GenericDataConn[int]](hub, "generic")
+}
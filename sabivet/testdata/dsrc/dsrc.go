@@ -0,0 +1,35 @@
+package dsrc
+
+import (
+	"github.com/sttk/errs"
+	"github.com/sttk/sabi"
+)
+
+type FooDataSrc struct{}
+
+func (ds *FooDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *FooDataSrc) Close()                             {}
+func (ds *FooDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) { // want CreateDataConn:`dataConnTypes\(\*vettest/dsrc.FooDataConn\)`
+	return &FooDataConn{}, errs.Ok()
+}
+
+type FooDataConn struct{}
+
+func (conn *FooDataConn) PreCommit(ag *sabi.AsyncGroup) errs.Err  { return errs.Ok() }
+func (conn *FooDataConn) Commit(ag *sabi.AsyncGroup) errs.Err     { return errs.Ok() }
+func (conn *FooDataConn) PostCommit(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (conn *FooDataConn) IsCommitted() bool                       { return false }
+func (conn *FooDataConn) Rollback(ag *sabi.AsyncGroup) errs.Err   { return errs.Ok() }
+func (conn *FooDataConn) OnTxnFailure(ag *sabi.AsyncGroup, reports []sabi.TxnFailureReport) {
+}
+func (conn *FooDataConn) Close() {}
+
+type OpaqueDataSrc struct {
+	New func() sabi.DataConn
+}
+
+func (ds *OpaqueDataSrc) Setup(ag *sabi.AsyncGroup) errs.Err { return errs.Ok() }
+func (ds *OpaqueDataSrc) Close()                             {}
+func (ds *OpaqueDataSrc) CreateDataConn() (sabi.DataConn, errs.Err) {
+	return ds.New(), errs.Ok()
+}
@@ -0,0 +1,10 @@
+module vettest
+
+go 1.23
+
+require (
+	github.com/sttk/errs v0.2.0
+	github.com/sttk/sabi v0.0.0
+)
+
+replace github.com/sttk/sabi => ../..
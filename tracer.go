@@ -0,0 +1,285 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sttk/errs"
+)
+
+// SpanAttr is a key-value pair attached to a Span. The keys are the same as the LogKey constants.
+type SpanAttr struct {
+	Key   string
+	Value any
+}
+
+// Span is an interface representing a unit of traced work.
+// Since sabi knows the times of phases other than Run and Txn only after they finish, the start
+// and end times are passed explicitly. This interface is minimal so that it can be bridged easily
+// to tracing libraries such as OpenTelemetry.
+type Span interface {
+	// SetAttributes attaches attributes to this span.
+	SetAttributes(attrs ...SpanAttr)
+	// SetError records that the work of this span failed with the error.
+	SetError(err errs.Err)
+	// End finishes this span at the specified time.
+	End(end time.Time)
+}
+
+// Tracer is an interface to create Spans.
+type Tracer interface {
+	// Start creates a new Span which started at the specified time, as a child of the span
+	// carried by ctx, or as a root span if ctx carries no span. It returns a context which carries
+	// the new span, so that the spans started with it become children of the new span.
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+// The following constants are the names of the Spans created by sabi.
+const (
+	// SpanNameRun is the name of the parent span of a Run.
+	SpanNameRun = "sabi.Run"
+	// SpanNameTxn is the name of the parent span of a Txn.
+	SpanNameTxn = "sabi.Txn"
+	// SpanNameSetupDataSrc is the name of the span of the setup of a data source.
+	SpanNameSetupDataSrc = "sabi.SetupDataSrc"
+	// SpanNameCloseDataSrc is the name of the span of the close of a data source.
+	SpanNameCloseDataSrc = "sabi.CloseDataSrc"
+	// SpanNameCreateDataConn is the name of the span of the creation of a DataConn.
+	SpanNameCreateDataConn = "sabi.CreateDataConn"
+	// SpanNamePreCommit is the name of the span of PreCommit of a DataConn.
+	SpanNamePreCommit = "sabi.PreCommit"
+	// SpanNameCommit is the name of the span of Commit of a DataConn.
+	SpanNameCommit = "sabi.Commit"
+	// SpanNamePostCommit is the name of the span of PostCommit of a DataConn.
+	SpanNamePostCommit = "sabi.PostCommit"
+	// SpanNameRollback is the name of the span of Rollback of a DataConn.
+	SpanNameRollback = "sabi.Rollback"
+	// SpanNameOnTxnFailure is the name of the span of OnTxnFailure of a DataConn.
+	SpanNameOnTxnFailure = "sabi.OnTxnFailure"
+)
+
+var globalTracer Tracer
+
+// SetTracer sets a Tracer which creates spans for Run and Txn of all DataHubs created after this
+// call, and for setup and close of data sources. Passing nil disables tracing, which is the
+// default. Like Uses, this setting must occur before Setup is called.
+//
+// A Run or Txn starts a parent span when it begins, as a child of the span carried by the context
+// set by DataHub.SetTraceContext, and creates child spans for the setup of local data sources,
+// each creation of DataConn, and each commit or rollback phase of each DataConn when they finish.
+// The spans of the setup of global data sources and the close of data sources are created outside
+// of Run and Txn, so they are children of the span carried by the context of the DataHub, or root
+// spans for global data sources.
+func SetTracer(tracer Tracer) {
+	if !globalDataSrcsFixed.Load() {
+		globalTracer = tracer
+	}
+}
+
+func spanNameOf(phase Phase) string {
+	var s string
+	switch phase {
+	case PhaseGetDataConn:
+		s = SpanNameCreateDataConn
+	case PhasePreCommit:
+		s = SpanNamePreCommit
+	case PhaseCommit:
+		s = SpanNameCommit
+	case PhasePostCommit:
+		s = SpanNamePostCommit
+	case PhaseRollback:
+		s = SpanNameRollback
+	case PhaseOnTxnFailure:
+		s = SpanNameOnTxnFailure
+	case PhaseSetupDataSrc:
+		s = SpanNameSetupDataSrc
+	case PhaseCloseDataSrc:
+		s = SpanNameCloseDataSrc
+	}
+	return s
+}
+
+// startTrace starts the parent span of a Run or Txn.
+// This is called at the beginning of it, before the events of its phases are notified.
+func (o *observer) startTrace(txn bool, start time.Time) {
+	if o.tracer == nil {
+		return
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	name := SpanNameRun
+	if txn {
+		name = SpanNameTxn
+	}
+	o.spanCtx, o.span = o.tracer.Start(o.traceContext(), name, start)
+	o.span.SetAttributes(SpanAttr{Key: LogKeyHubId, Value: o.hubId})
+}
+
+func (o *observer) traceContext() context.Context {
+	if o.spanCtx != nil {
+		return o.spanCtx
+	}
+	if o.ctx != nil {
+		return o.ctx
+	}
+	return context.Background()
+}
+
+func (o *observer) traceEvent(ev Event) {
+	switch ev.Phase {
+	case PhaseBegin:
+		if ev.Err.IsNotOk() {
+			o.endTrace(ev)
+		}
+	case PhaseEnd:
+		o.endTrace(ev)
+	default:
+		o.traceSpan(ev)
+	}
+}
+
+func (o *observer) endTrace(ev Event) {
+	if o.span == nil {
+		return
+	}
+	if ev.Err.IsNotOk() {
+		o.span.SetError(ev.Err)
+	}
+	o.span.End(ev.Start.Add(ev.Duration))
+	o.spanCtx, o.span = nil, nil
+}
+
+func (o *observer) traceSpan(ev Event) {
+	_, span := o.tracer.Start(o.traceContext(), spanNameOf(ev.Phase), ev.Start)
+
+	attrs := make([]SpanAttr, 0, 6)
+	attrs = append(attrs, SpanAttr{Key: LogKeyHubId, Value: ev.HubId})
+	if len(ev.DataConnName) > 0 {
+		attrs = append(attrs, SpanAttr{Key: LogKeyName, Value: ev.DataConnName})
+	}
+	if len(ev.DataConnType) > 0 {
+		attrs = append(attrs, SpanAttr{Key: LogKeyDataConnType, Value: ev.DataConnType})
+	}
+	if len(ev.DataSrcType) > 0 {
+		attrs = append(attrs, SpanAttr{Key: LogKeyDataSrcType, Value: ev.DataSrcType})
+	}
+	if ev.Report != nil {
+		attrs = append(attrs,
+			SpanAttr{Key: LogKeyCauseState, Value: ev.Report.Cause.State.String()},
+			SpanAttr{Key: LogKeyRollbackState, Value: ev.Report.Rollback.State.String()},
+		)
+	}
+	span.SetAttributes(attrs...)
+
+	if ev.Err.IsNotOk() {
+		span.SetError(ev.Err)
+	}
+	span.End(ev.Start.Add(ev.Duration))
+}
+
+// RecordedSpan is a span recorded by a SpanRecorder.
+type RecordedSpan struct {
+	// Id is the identifier of this span, which is its index in the recorded spans plus one.
+	Id int
+	// ParentId is the identifier of the parent span, or zero if this span is a root span.
+	ParentId int
+	// Name is the name of this span.
+	Name string
+	// Start is the time when this span started.
+	Start time.Time
+	// End is the time when this span ended, or the zero time if it has not ended.
+	End time.Time
+	// Attrs is the attributes attached to this span.
+	Attrs map[string]any
+	// Err is the error recorded to this span.
+	Err errs.Err
+}
+
+// SpanRecorder is a Tracer which records spans in memory. This is useful for tests.
+// It is safe for concurrent use.
+type SpanRecorder struct {
+	mutex sync.Mutex
+	spans []RecordedSpan
+}
+
+type recorderSpan struct {
+	recorder *SpanRecorder
+	index    int
+}
+
+type recorderSpanKey struct{}
+
+// NewSpanRecorder creates a new SpanRecorder.
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{spans: make([]RecordedSpan, 0)}
+}
+
+// Start creates a new Span and records it. The parent is the span started by this recorder and
+// carried by ctx.
+func (r *SpanRecorder) Start(ctx context.Context, name string, start time.Time) (
+	context.Context, Span) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	parentId := 0
+	if p, ok := ctx.Value(recorderSpanKey{}).(*recorderSpan); ok && p.recorder == r {
+		parentId = p.index + 1
+	}
+
+	index := len(r.spans)
+	r.spans = append(r.spans, RecordedSpan{
+		Id:       index + 1,
+		ParentId: parentId,
+		Name:     name,
+		Start:    start,
+		Attrs:    make(map[string]any),
+	})
+	span := &recorderSpan{recorder: r, index: index}
+	return context.WithValue(ctx, recorderSpanKey{}, span), span
+}
+
+// Spans returns a copy of the recorded spans in the order in which they were started.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	spans := make([]RecordedSpan, len(r.spans))
+	for i := range r.spans {
+		spans[i] = r.spans[i]
+		spans[i].Attrs = make(map[string]any, len(r.spans[i].Attrs))
+		for k, v := range r.spans[i].Attrs {
+			spans[i].Attrs[k] = v
+		}
+	}
+	return spans
+}
+
+func (s *recorderSpan) SetAttributes(attrs ...SpanAttr) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+
+	for _, attr := range attrs {
+		s.recorder.spans[s.index].Attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recorderSpan) SetError(err errs.Err) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+
+	s.recorder.spans[s.index].Err = err
+}
+
+func (s *recorderSpan) End(end time.Time) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+
+	s.recorder.spans[s.index].End = end
+}
@@ -0,0 +1,248 @@
+package sabi
+
+import (
+	"container/list"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+func spanSummary(spans []RecordedSpan) []string {
+	a := make([]string, 0, len(spans))
+	for _, span := range spans {
+		s := span.Name
+		if name, ok := span.Attrs[LogKeyName]; ok {
+			s += " " + name.(string)
+		}
+		if span.Err.IsNotOk() {
+			s += " failed"
+		}
+		a = append(a, s)
+	}
+	return a
+}
+
+func TestSpanRecorder(t *testing.T) {
+	r := NewSpanRecorder()
+	other := NewSpanRecorder()
+
+	now := time.Now()
+	ctx, parent := r.Start(context.Background(), "parent", now)
+	_, child := r.Start(ctx, "child", now)
+	child.SetAttributes(SpanAttr{Key: "k", Value: 1})
+	child.SetError(errs.New("fail"))
+	child.End(now.Add(2))
+	parent.End(now.Add(3))
+	otherCtx, _ := other.Start(context.Background(), "x", now)
+	_, orphan := r.Start(otherCtx, "orphan", now)
+	orphan.End(now)
+
+	spans := r.Spans()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, spans[0].Id, 1)
+	assert.Equal(t, spans[0].ParentId, 0)
+	assert.Equal(t, spans[0].Name, "parent")
+	assert.Equal(t, spans[0].End, now.Add(3))
+	assert.Equal(t, spans[1].Id, 2)
+	assert.Equal(t, spans[1].ParentId, 1)
+	assert.Equal(t, spans[1].Attrs["k"], 1)
+	assert.Equal(t, spans[1].Err.Reason(), "fail")
+	assert.Equal(t, spans[2].ParentId, 0)
+
+	spans[1].Attrs["k"] = 2
+	assert.Equal(t, r.Spans()[1].Attrs["k"], 1)
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("global data srcs", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		r := NewSpanRecorder()
+		SetTracer(r)
+
+		logger := list.New()
+		Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+		assert.True(t, Setup().IsOk())
+		Shutdown()
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{
+			"sabi.SetupDataSrc foo",
+			"sabi.CloseDataSrc foo",
+		})
+		for _, span := range spans {
+			assert.Equal(t, span.ParentId, 0)
+			assert.Equal(t, span.Attrs[LogKeyHubId], uint64(0))
+			assert.Equal(t, span.Attrs[LogKeyDataSrcType], "*sabi.MyDataSrc")
+			assert.False(t, span.End.Before(span.Start))
+		}
+	})
+
+	t.Run("txn and ok", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		r := NewSpanRecorder()
+		SetTracer(r)
+
+		logger := list.New()
+		hub := NewDataHub()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			_, err := GetDataConn[*MyDataConn](data, "foo")
+			return err
+		})
+		assert.True(t, err.IsOk())
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{
+			"sabi.Txn",
+			"sabi.SetupDataSrc foo",
+			"sabi.CreateDataConn foo",
+			"sabi.PreCommit foo",
+			"sabi.Commit foo",
+			"sabi.PostCommit foo",
+		})
+		assert.Equal(t, spans[0].ParentId, 0)
+		assert.Equal(t, spans[0].Attrs[LogKeyHubId], hub.(*dataHubImpl).observer.hubId)
+		for _, span := range spans[1:] {
+			assert.Equal(t, span.ParentId, spans[0].Id)
+			assert.False(t, span.Start.Before(spans[0].Start))
+			assert.False(t, span.End.After(spans[0].End))
+		}
+		assert.Equal(t, spans[2].Attrs[LogKeyDataConnType], "*sabi.MyDataConn")
+
+		hub.Close()
+		spans = r.Spans()
+		assert.Equal(t, spans[6].Name, "sabi.CloseDataSrc")
+		assert.Equal(t, spans[6].ParentId, 0)
+	})
+
+	t.Run("txn and rollback", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+
+		r := NewSpanRecorder()
+		hub.SetTracer(r)
+		hub.Uses("foo", NewMyDataSrc(1, Failure_Commit, logger))
+
+		err := Txn(hub, func(data any) errs.Err {
+			_, err := GetDataConn[*MyDataConn](data, "foo")
+			return err
+		})
+		assert.True(t, err.IsNotOk())
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{
+			"sabi.Txn failed",
+			"sabi.SetupDataSrc foo",
+			"sabi.CreateDataConn foo",
+			"sabi.PreCommit foo",
+			"sabi.Commit foo failed",
+			"sabi.Rollback foo",
+			"sabi.OnTxnFailure foo",
+		})
+		assert.Equal(t, spans[0].Err, err)
+		assert.Equal(t, spans[5].Attrs[LogKeyCauseState], "CommitFailure")
+		assert.Equal(t, spans[5].Attrs[LogKeyRollbackState], "NoneByRolledBack")
+		assert.Equal(t, spans[6].Attrs[LogKeyCauseState], "CommitFailure")
+	})
+
+	t.Run("run but failed to setup", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		r := NewSpanRecorder()
+		SetTracer(r)
+
+		logger := list.New()
+		hub := NewDataHub()
+		defer hub.Close()
+		hub.Uses("foo", NewMyDataSrc(1, Failure_Setup, logger))
+
+		err := Run(hub, func(data any) errs.Err { return errs.Ok() })
+		assert.True(t, err.IsNotOk())
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{
+			"sabi.Run failed",
+			"sabi.SetupDataSrc foo failed",
+		})
+		assert.Equal(t, spans[1].ParentId, spans[0].Id)
+	})
+
+	t.Run("run without data conns", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		r := NewSpanRecorder()
+		SetTracer(r)
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		err := Run(hub, func(data any) errs.Err { return errs.New("logic error") })
+		assert.Equal(t, err.Reason(), "logic error")
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{"sabi.Run failed"})
+	})
+
+	t.Run("txn in trace context", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		r := NewSpanRecorder()
+		SetTracer(r)
+
+		ctx, request := r.Start(context.Background(), "request", time.Now())
+
+		logger := list.New()
+		hub := NewDataHub()
+		hub.SetTraceContext(ctx)
+		hub.Uses("foo", NewMyDataSrc(1, Failure_None, logger))
+
+		var spansInLogic []RecordedSpan
+		err := Txn(hub, func(data any) errs.Err {
+			spansInLogic = r.Spans()
+			_, err := GetDataConn[*MyDataConn](data, "foo")
+			return err
+		})
+		assert.True(t, err.IsOk())
+		hub.Close()
+		request.End(time.Now())
+
+		assert.Equal(t, spanSummary(spansInLogic), []string{
+			"request",
+			"sabi.Txn",
+			"sabi.SetupDataSrc foo",
+		})
+		assert.True(t, spansInLogic[1].End.IsZero())
+
+		spans := r.Spans()
+		assert.Equal(t, spanSummary(spans), []string{
+			"request",
+			"sabi.Txn",
+			"sabi.SetupDataSrc foo",
+			"sabi.CreateDataConn foo",
+			"sabi.PreCommit foo",
+			"sabi.Commit foo",
+			"sabi.PostCommit foo",
+			"sabi.CloseDataSrc foo",
+		})
+		assert.Equal(t, spans[1].ParentId, spans[0].Id)
+		for _, span := range spans[2:7] {
+			assert.Equal(t, span.ParentId, spans[1].Id)
+		}
+		assert.Equal(t, spans[7].ParentId, spans[0].Id)
+	})
+}
@@ -161,6 +161,30 @@ const (
 	ManualRollbackRequired
 )
 
+// String returns the string representation of the TxnFailureRecovery.
+//
+// It maps the TxnFailureRecovery enum value to its corresponding string literal name.
+func (recovery TxnFailureRecovery) String() string {
+	var s string
+	switch recovery {
+	case NoActionRequired:
+		s = "NoActionRequired"
+	case RerunLogicAndCommit:
+		s = "RerunLogicAndCommit"
+	case ResolveCauseThenRerunLogicAndCommit:
+		s = "ResolveCauseThenRerunLogicAndCommit"
+	case ResolveCauseThenRerunPostCommit:
+		s = "ResolveCauseThenRerunPostCommit"
+	case ResolveCauseAndInconsistency:
+		s = "ResolveCauseAndInconsistency"
+	case InvestigateBecauseImpossible:
+		s = "InvestigateBecauseImpossible"
+	case ManualRollbackRequired:
+		s = "ManualRollbackRequired"
+	}
+	return s
+}
+
 // TxnFailureReport aggregates details about a transaction failure for a specific
 // data connection.
 //
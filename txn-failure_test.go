@@ -225,3 +225,17 @@ func TestTxnFailureReport(t *testing.T) {
 		}
 	})
 }
+
+func TestTxnFailureRecovery(t *testing.T) {
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, NoActionRequired.String(), "NoActionRequired")
+		assert.Equal(t, RerunLogicAndCommit.String(), "RerunLogicAndCommit")
+		assert.Equal(t, ResolveCauseThenRerunLogicAndCommit.String(),
+			"ResolveCauseThenRerunLogicAndCommit")
+		assert.Equal(t, ResolveCauseThenRerunPostCommit.String(), "ResolveCauseThenRerunPostCommit")
+		assert.Equal(t, ResolveCauseAndInconsistency.String(), "ResolveCauseAndInconsistency")
+		assert.Equal(t, InvestigateBecauseImpossible.String(), "InvestigateBecauseImpossible")
+		assert.Equal(t, ManualRollbackRequired.String(), "ManualRollbackRequired")
+		assert.Equal(t, TxnFailureRecovery(99).String(), "")
+	})
+}
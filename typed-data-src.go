@@ -0,0 +1,101 @@
+// Copyright (C) 2026 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"reflect"
+
+	"github.com/sttk/errs"
+)
+
+// TypedDataSrc is a data source whose CreateDataConn returns DataConns of the static type C.
+//
+// A TypedDataSrc is registered by UsesTyped or UsesTypedIn instead of Uses, and the returned
+// DataSrcKey is passed to GetDataConnOf to retrieve a DataConn of type C without a type argument
+// which may not match the registered data source.
+type TypedDataSrc[C DataConn] interface {
+	// Setup is the same as DataSrc.Setup.
+	Setup(ag *AsyncGroup) errs.Err
+
+	// Close is the same as DataSrc.Close.
+	Close()
+
+	// CreateDataConn is the same as DataSrc.CreateDataConn, except that it returns the created
+	// connection as type C.
+	CreateDataConn() (C, errs.Err)
+}
+
+// DataSrcKey is a key of a TypedDataSrc registered with a name, which statically records the type
+// of the DataConns created by the data source.
+type DataSrcKey[C DataConn] struct {
+	name string
+}
+
+// Name returns the name with which the data source of this key is registered.
+func (key DataSrcKey[C]) Name() string {
+	return key.name
+}
+
+type typedDataSrc[C DataConn] struct {
+	ds TypedDataSrc[C]
+}
+
+func (ds *typedDataSrc[C]) Setup(ag *AsyncGroup) errs.Err {
+	return ds.ds.Setup(ag)
+}
+
+func (ds *typedDataSrc[C]) Close() {
+	ds.ds.Close()
+}
+
+func (ds *typedDataSrc[C]) CreateDataConn() (DataConn, errs.Err) {
+	c, err := ds.ds.CreateDataConn()
+	if err.IsNotOk() {
+		return nil, err
+	}
+	if v := reflect.ValueOf(c); !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return nil, errs.Ok()
+	}
+	return c, errs.Ok()
+}
+
+func (ds *typedDataSrc[C]) DataConnType() reflect.Type {
+	return reflect.TypeFor[C]()
+}
+
+func (ds *typedDataSrc[C]) wrappedDataSrc() any {
+	return ds.ds
+}
+
+func dataSrcTypeNameOf(ds any) string {
+	for {
+		w, ok := unwrapDataSrc(ds)
+		if !ok {
+			return typeNameOf(ds)
+		}
+		ds = w
+	}
+}
+
+// UsesTyped registers a global TypedDataSrc with the name like Uses, and returns the key to
+// retrieve its DataConns by GetDataConnOf.
+func UsesTyped[C DataConn](name string, ds TypedDataSrc[C]) DataSrcKey[C] {
+	Uses(name, &typedDataSrc[C]{ds: ds})
+	return DataSrcKey[C]{name: name}
+}
+
+// UsesTypedIn registers a local TypedDataSrc with the name to the hub like DataHub.Uses, and
+// returns the key to retrieve its DataConns by GetDataConnOf.
+func UsesTypedIn[C DataConn](hub DataHub, name string, ds TypedDataSrc[C]) DataSrcKey[C] {
+	hub.Uses(name, &typedDataSrc[C]{ds: ds})
+	return DataSrcKey[C]{name: name}
+}
+
+// GetDataConnOf retrieves a DataConn of the data source registered with the key like
+// GetDataConn. Since the type of the DataConn is determined by the key, the cast of the
+// DataConn does not fail unless another data source is registered with the same name.
+func GetDataConnOf[C DataConn](data any, key DataSrcKey[C]) (C, errs.Err) {
+	return GetDataConn[C](data, key.name)
+}
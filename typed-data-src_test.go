@@ -0,0 +1,161 @@
+package sabi
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sttk/errs"
+)
+
+type MyTypedDataSrc struct {
+	src *MyDataSrc
+}
+
+func NewMyTypedDataSrc(id uint8, failure Failure, logger *list.List) *MyTypedDataSrc {
+	return &MyTypedDataSrc{src: NewMyDataSrc(id, failure, logger)}
+}
+
+func (ds *MyTypedDataSrc) Setup(ag *AsyncGroup) errs.Err {
+	return ds.src.Setup(ag)
+}
+
+func (ds *MyTypedDataSrc) Close() {
+	ds.src.Close()
+}
+
+func (ds *MyTypedDataSrc) CreateDataConn() (*MyDataConn, errs.Err) {
+	dc, err := ds.src.CreateDataConn()
+	if err.IsNotOk() || dc == nil {
+		return nil, err
+	}
+	return dc.(*MyDataConn), errs.Ok()
+}
+
+func TestTypedDataSrc(t *testing.T) {
+	t.Run("UsesTypedIn and GetDataConnOf", func(t *testing.T) {
+		logger := list.New()
+
+		var dataSrcTypes []string
+		func() {
+			hub := NewDataHub()
+			defer hub.Close()
+			hub.AddListener(ListenerFunc(func(ev Event) {
+				if ev.Phase == PhaseSetupDataSrc {
+					dataSrcTypes = append(dataSrcTypes, ev.DataSrcType)
+				}
+			}))
+
+			key := UsesTypedIn(hub, "foo", NewMyTypedDataSrc(1, Failure_None, logger))
+			assert.Equal(t, key.Name(), "foo")
+
+			err := Txn(hub, func(data any) errs.Err {
+				conn, err := GetDataConnOf(data, key)
+				assert.True(t, err.IsOk())
+				assert.Equal(t, conn.id, uint8(1))
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		assert.Equal(t, dataSrcTypes, []string{"*sabi.MyTypedDataSrc"})
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PreCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Commit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#PostCommit 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("UsesTyped", func(t *testing.T) {
+		ResetGlobals()
+		defer ResetGlobals()
+
+		logger := list.New()
+
+		key := UsesTyped("foo", NewMyTypedDataSrc(1, Failure_None, logger))
+		assert.Equal(t, key.Name(), "foo")
+
+		func() {
+			assert.True(t, Setup().IsOk())
+			defer Shutdown()
+
+			hub := NewDataHub()
+			defer hub.Close()
+
+			err := Run(hub, func(data any) errs.Err {
+				conn, err := GetDataConnOf(data, key)
+				assert.True(t, err.IsOk())
+				assert.Equal(t, conn.id, uint8(1))
+				return err
+			})
+			assert.True(t, err.IsOk())
+		}()
+
+		log := logger.Front()
+		assert.Equal(t, log.Value, "MyDataSrc#Setup 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#CreateDataConn 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataConn#Close 1")
+		log = log.Next()
+		assert.Equal(t, log.Value, "MyDataSrc#Close 1")
+		log = log.Next()
+		assert.Nil(t, log)
+	})
+
+	t.Run("fail to create data conn", func(t *testing.T) {
+		logger := list.New()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		key := UsesTypedIn(hub, "foo", NewMyTypedDataSrc(1, Failure_CreateDataConn, logger))
+
+		err := Run(hub, func(data any) errs.Err {
+			_, err := GetDataConnOf(data, key)
+			return err
+		})
+		assert.True(t, err.IsNotOk())
+		switch rsn := err.Reason().(type) {
+		case FailToCreateDataConn:
+			assert.Equal(t, rsn.Name, "foo")
+			assert.Equal(t, rsn.DataConnType, "*sabi.MyDataConn")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+
+	t.Run("created data conn is nil", func(t *testing.T) {
+		logger := list.New()
+
+		hub := NewDataHub()
+		defer hub.Close()
+
+		key := UsesTypedIn(hub, "foo", NewMyTypedDataSrc(1, Failure_CreatedDataConnIsNil, logger))
+
+		err := Run(hub, func(data any) errs.Err {
+			_, err := GetDataConnOf(data, key)
+			return err
+		})
+		assert.True(t, err.IsNotOk())
+		switch rsn := err.Reason().(type) {
+		case CreatedDataConnIsNil:
+			assert.Equal(t, rsn.Name, "foo")
+			assert.Equal(t, rsn.DataConnType, "*sabi.MyDataConn")
+		default:
+			assert.Fail(t, err.Error())
+		}
+	})
+}